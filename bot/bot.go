@@ -8,13 +8,14 @@ import (
 	"komainu/interactions/component"
 	"komainu/interactions/delete"
 	"komainu/interactions/edit"
+	"komainu/interactions/guildcreate"
+	"komainu/interactions/guilddelete"
 	"komainu/interactions/join"
 	"komainu/interactions/leave"
 	"komainu/interactions/message"
 	"komainu/interactions/modal"
 	"komainu/storage"
 	"log"
-	"os"
 
 	"github.com/diamondburned/arikawa/v3/gateway"
 	"github.com/diamondburned/arikawa/v3/state"
@@ -22,9 +23,9 @@ import (
 
 // Connect connects to Discord
 func Connect(cfg *storage.Configuration, kvs storage.KeyValueStore) *state.State {
-	var token = os.Getenv("BOT_TOKEN")
-	if token == "" {
-		log.Fatalln("No BOT_TOKEN found in environment variables.")
+	token, err := cfg.BotToken()
+	if err != nil {
+		log.Fatalln("Could not get the bot token:", err)
 	}
 
 	state := state.New("Bot " + token)
@@ -40,6 +41,8 @@ func Connect(cfg *storage.Configuration, kvs storage.KeyValueStore) *state.State
 	edit.AddHandler(state, kvs)
 	join.AddHandler(state, kvs)
 	leave.AddHandler(state, kvs)
+	guilddelete.AddHandler(state, kvs)
+	guildcreate.AddHandler(state, kvs)
 
 	if err := state.Open(context.Background()); err != nil {
 		log.Fatalln("Failed to connect to Discord:", err)
@@ -51,14 +54,17 @@ func Connect(cfg *storage.Configuration, kvs storage.KeyValueStore) *state.State
 	}
 	log.Printf("Connected to Discord as %s#%s\n", user.Username, user.Discriminator)
 
-	if err := command.RegisterCommands(state); err != nil {
+	if err := command.RegisterCommands(state.Client, cfg, false); err != nil {
 		log.Fatalf("Error during command registration: %s", err)
 	}
 
 	// I was wondering if this should be init() in those specific files.
 	// This is a bad idea, however, as they only really work after connecting.
-	go storage.StartClosingExpiredVotes(state, kvs)
+	storage.OnExpire("votes", storage.CloseExpiredVote(state))
+	go storage.StartSweepingExpiredKeys(kvs)
 	go storage.StartRevokingActiveRole(state, kvs)
+	go storage.StartFlushingSeen(kvs)
+	go storage.StartBackingUp(kvs)
 
 	return state
 }
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"komainu/interactions/command"
+	"komainu/storage"
+	"os"
+	"path/filepath"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// cliModes holds the things Komainu can do from the command line instead of connecting to Discord.
+var cliModes = map[string]func(args []string) error{
+	"export":   cliExport,
+	"import":   cliImport,
+	"migrate":  cliMigrate,
+	"stats":    cliStats,
+	"compact":  cliCompact,
+	"commands": cliCommands,
+}
+
+// launchDir is the working directory Komainu was started in.
+var launchDir string
+
+// cliPath makes a path given on the command line relative to where Komainu was started, rather than where it lives.
+func cliPath(path string) string {
+	if filepath.IsAbs(path) || launchDir == "" {
+		return path
+	}
+	return filepath.Join(launchDir, path)
+}
+
+// runCLI runs the command line mode named by the first argument.
+func runCLI(args []string) error {
+	mode, ok := cliModes[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown mode %q", args[0])
+	}
+	return mode(args[1:])
+}
+
+// parseGuildID turns a command line argument into a GuildID.
+func parseGuildID(raw string) (discord.GuildID, error) {
+	snowflake, err := discord.ParseSnowflake(raw)
+	if err != nil {
+		return discord.NullGuildID, fmt.Errorf("%q is not a valid guild ID: %w", raw, err)
+	}
+	return discord.GuildID(snowflake), nil
+}
+
+// cliExport dumps a guild to JSON.
+// Usage: komainu export --guild <id> [--out file.json]
+func cliExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	guild := flags.String("guild", "", "The ID of the guild to export")
+	out := flags.String("out", "", "File to write the export to, instead of STDOUT")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *guild == "" {
+		return errors.New("export needs a --guild")
+	}
+	guildID, err := parseGuildID(*guild)
+	if err != nil {
+		return err
+	}
+
+	kvs := openKVS(storage.GetConfiguration())
+	defer kvs.Close()
+
+	export, err := storage.ExportGuild(kvs, guildID)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		fileHandle, err := os.Create(cliPath(*out))
+		if err != nil {
+			return err
+		}
+		defer fileHandle.Close()
+		w = fileHandle
+	}
+	return storage.WriteGuildExport(w, export)
+}
+
+// cliImport restores a guild from a JSON export.
+// Usage: komainu import [--guild <id>] file.json
+func cliImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	guild := flags.String("guild", "", "Import into this guild instead of the one the export came from")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("import needs exactly one file to read")
+	}
+
+	fileHandle, err := os.Open(cliPath(flags.Arg(0)))
+	if err != nil {
+		return err
+	}
+	defer fileHandle.Close()
+	export, err := storage.ReadGuildExport(fileHandle)
+	if err != nil {
+		return fmt.Errorf("could not read export: %w", err)
+	}
+
+	guildID := export.GuildID
+	if *guild != "" {
+		if guildID, err = parseGuildID(*guild); err != nil {
+			return err
+		}
+	}
+
+	kvs := openKVS(storage.GetConfiguration())
+	defer kvs.Close()
+
+	count, err := storage.ImportGuild(kvs, guildID, export)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d keys into guild %s\n", count, guildID)
+	return nil
+}
+
+// cliMigrate copies a database from the old flat bucket layout into the current one.
+// Usage: komainu migrate --from old.bolt
+func cliMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	from := flags.String("from", "", "The legacy database file to migrate from")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" {
+		return errors.New("migrate needs a --from")
+	}
+
+	kvs := openKVS(storage.GetConfiguration())
+	defer kvs.Close()
+
+	report, err := storage.MigrateLegacyBolt(cliPath(*from), kvs)
+	if report != nil {
+		fmt.Print(report)
+	}
+	if err != nil {
+		return err
+	}
+	if !report.OK() {
+		return errors.New("migration finished, but not everything made it across")
+	}
+	fmt.Println("Migration finished, and every value was verified.")
+	return nil
+}
+
+// cliStats prints what is using the space in the database.
+// Usage: komainu stats
+func cliStats(args []string) error {
+	kvs := openKVS(storage.GetConfiguration())
+	defer kvs.Close()
+
+	stats, err := storage.Stats(kvs)
+	if err != nil {
+		return err
+	}
+	fmt.Print(stats)
+	return nil
+}
+
+// cliCompact gets rid of the space left behind by deleted keys.
+// Usage: komainu compact
+func cliCompact(args []string) error {
+	kvs := openKVS(storage.GetConfiguration())
+	defer kvs.Close()
+
+	before, after, err := storage.Compact(kvs)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Compacted the database from %d to %d bytes\n", before, after)
+	return nil
+}
+
+// cliCommands shows how the commands registered with Discord differ from the ones the bot has, and fixes that if asked to.
+// Like when connecting, dev mode looks at the dev guilds rather than the global commands.
+// Usage: komainu commands [--apply]
+func cliCommands(args []string) error {
+	flags := flag.NewFlagSet("commands", flag.ContinueOnError)
+	apply := flags.Bool("apply", false, "Register the commands, rather than just showing the differences")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	cfg := storage.GetConfiguration()
+	token, err := cfg.BotToken()
+	if err != nil {
+		return err
+	}
+	return command.RegisterCommands(api.NewClient("Bot "+token), &cfg, !*apply)
+}
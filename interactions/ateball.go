@@ -21,6 +21,8 @@ func init() {
 var commandAteHandler = command.Handler{
 	Description: "Ask the mystical ateball!",
 	Code:        CommandAte,
+	Access:      command.EveryoneAccess,
+	Cooldown:    command.Cooldown{Per: command.PerChannel, Duration: 15 * time.Second},
 	Options: []discord.CommandOption{
 		&discord.StringOption{
 			OptionName:  "question",
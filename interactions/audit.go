@@ -0,0 +1,222 @@
+package interactions
+
+import (
+	"fmt"
+	"komainu/interactions/autocomplete"
+	"komainu/interactions/command"
+	"komainu/interactions/component"
+	"komainu/interactions/response"
+	"komainu/storage"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/state"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+// auditPageSize is how many audit log entries are shown at a time.
+const auditPageSize = 10
+
+// auditChannel is where every audit log entry is mirrored to. Unset means they aren't.
+var auditChannel = storage.ChannelSetting("audit.channel", "Channel to post every audit log entry in, as it happens")
+
+func init() {
+	command.Register("auditlog", commandAuditLogObject)
+	component.Register("auditlog", component.Handler{Code: ComponentAuditLog})
+	autocomplete.Register("auditlog", autocomplete.Handler{Code: AuditLogAutocomplete})
+}
+
+var commandAuditLogObject = command.Handler{
+	Description: "See who changed what, and when",
+	Code:        CommandAuditLog,
+	Access:      command.ModeratorAccess,
+	Options: []discord.CommandOption{
+		&discord.StringOption{
+			OptionName:   "feature",
+			Description:  "Only show changes to this feature",
+			Required:     false,
+			Autocomplete: true,
+		},
+		&discord.UserOption{
+			OptionName:  "user",
+			Description: "Only show changes made by this user",
+			Required:    false,
+		},
+		&discord.IntegerOption{
+			OptionName:  "page",
+			Description: "Which page to start at, the newest changes being on page 1",
+			Required:    false,
+			Min:         option.NewInt(1),
+		},
+	},
+}
+
+// mirrorAudit posts an audit log entry that was just recorded to the audit channel, if there is one.
+func mirrorAudit(state *state.State, kvs storage.KeyValueStore, guildID discord.GuildID, entry storage.AuditEntry) {
+	channelID, exist, err := auditChannel.Get(kvs, guildID)
+	if err != nil {
+		log.Printf("[%s] Failed to look up the audit channel: %s", guildID, err)
+		return
+	}
+	if !exist {
+		return
+	}
+	_, err = state.SendMessageComplex(channelID, api.SendMessageData{
+		Content: entry.String(),
+		AllowedMentions: &api.AllowedMentions{
+			Parse: []api.AllowedMentionType{},
+		},
+	})
+	if err != nil {
+		log.Printf("[%s] Failed to mirror audit entry to <#%s>: %s", guildID, channelID, err)
+	}
+}
+
+// CommandAuditLog processes a command to show the audit log.
+func CommandAuditLog(state *state.State, kvs storage.KeyValueStore, event *gateway.InteractionCreateEvent, cmd *discord.CommandInteraction) command.Response {
+	filter := storage.AuditFilter{}
+	page := 1
+	for _, opt := range cmd.Options {
+		switch opt.Name {
+		case "feature":
+			filter.Feature = strings.ToLower(strings.TrimSpace(opt.String()))
+		case "user":
+			snowflake, err := opt.SnowflakeValue()
+			if err != nil {
+				log.Printf("[%s] /auditlog failed to get the user snowflake: %s", event.GuildID, err)
+				return command.Response{Response: response.Ephemeral("An error occured, and has been logged.")}
+			}
+			filter.UserID = discord.UserID(snowflake)
+		case "page":
+			value, err := opt.IntValue()
+			if err != nil {
+				log.Printf("[%s] /auditlog failed to get the page number: %s", event.GuildID, err)
+				return command.Response{Response: response.Ephemeral("An error occured, and has been logged.")}
+			}
+			page = int(value)
+		}
+	}
+	// The feature goes into the button IDs, so only the ones actually in the audit log are taken.
+	if filter.Feature != "" {
+		features, err := storage.AuditFeatures(kvs, event.GuildID)
+		if err != nil {
+			log.Printf("[%s] /auditlog failed to look up the features: %s", event.GuildID, err)
+			return command.Response{Response: response.Ephemeral("An error occured, and has been logged.")}
+		}
+		known := false
+		for _, feature := range features {
+			known = known || feature == filter.Feature
+		}
+		if !known {
+			return command.Response{Response: response.Ephemeral("There is nothing in the audit log for that feature. Pick one of the suggestions.")}
+		}
+	}
+	data, err := auditLogPage(kvs, event.GuildID, filter, page)
+	if err != nil {
+		log.Printf("[%s] /auditlog failed to read the audit log: %s", event.GuildID, err)
+		return command.Response{Response: response.Ephemeral("An error occured, and has been logged.")}
+	}
+	data.Flags = api.EphemeralResponse
+	return command.Response{Response: api.InteractionResponse{Type: api.MessageInteractionWithSource, Data: data}}
+}
+
+// ComponentAuditLog handles the buttons for paging through the audit log.
+// The button IDs are auditlog/page/user/feature, so the filter carries over from page to page.
+func ComponentAuditLog(state *state.State, kvs storage.KeyValueStore, e *gateway.InteractionCreateEvent, interaction discord.ComponentInteraction) api.InteractionResponse {
+	parts := strings.SplitN(string(interaction.ID()), "/", 4)
+	if len(parts) != 4 {
+		log.Printf("[%s] Audit log button with a weird ID %q", e.GuildID, interaction.ID())
+		return response.Ephemeral("Something odd happened. It has been logged.")
+	}
+	page, err := strconv.Atoi(parts[1])
+	if err != nil {
+		log.Printf("[%s] Audit log button with a weird page in %q: %s", e.GuildID, interaction.ID(), err)
+		return response.Ephemeral("Something odd happened. It has been logged.")
+	}
+	filter := storage.AuditFilter{Feature: parts[3]}
+	if snowflake, err := discord.ParseSnowflake(parts[2]); err == nil {
+		filter.UserID = discord.UserID(snowflake)
+	}
+	data, err := auditLogPage(kvs, e.GuildID, filter, page)
+	if err != nil {
+		log.Printf("[%s] Audit log button failed to read the audit log: %s", e.GuildID, err)
+		return response.Ephemeral("An error occured, and has been logged.")
+	}
+	return api.InteractionResponse{Type: api.UpdateMessage, Data: data}
+}
+
+// auditLogPage formats a page of the audit log, with buttons to get to the pages either side of it.
+func auditLogPage(kvs storage.KeyValueStore, guildID discord.GuildID, filter storage.AuditFilter, page int) (*api.InteractionResponseData, error) {
+	if page < 1 {
+		page = 1
+	}
+	entries, total, err := storage.AuditLog(kvs, guildID, filter, (page-1)*auditPageSize, auditPageSize)
+	if err != nil {
+		return nil, err
+	}
+	pages := (total + auditPageSize - 1) / auditPageSize
+	if total == 0 {
+		return &api.InteractionResponseData{
+			Content:    option.NewNullableString("There is nothing in the audit log that matches."),
+			Components: &discord.ContainerComponents{},
+		}, nil
+	}
+	if page > pages {
+		return auditLogPage(kvs, guildID, filter, pages)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "**Audit log, page %d of %d:**\n", page, pages)
+	for _, entry := range entries {
+		fmt.Fprintln(&sb, entry.String())
+	}
+	buttonID := func(page int) discord.ComponentID {
+		return discord.ComponentID(fmt.Sprintf("auditlog/%d/%d/%s", page, filter.UserID, filter.Feature))
+	}
+	row := discord.ActionRowComponent([]discord.InteractiveComponent{
+		&discord.ButtonComponent{
+			Label:    "Newer",
+			CustomID: buttonID(page - 1),
+			Style:    discord.SecondaryButtonStyle(),
+			Disabled: page <= 1,
+		},
+		&discord.ButtonComponent{
+			Label:    "Older",
+			CustomID: buttonID(page + 1),
+			Style:    discord.SecondaryButtonStyle(),
+			Disabled: page >= pages,
+		},
+	})
+	return &api.InteractionResponseData{
+		Content:    option.NewNullableString(sb.String()),
+		Components: discord.ComponentsPtr(&row),
+		AllowedMentions: &api.AllowedMentions{
+			Parse: []api.AllowedMentionType{},
+		},
+	}, nil
+}
+
+// AuditLogAutocomplete suggests the features found in the audit log.
+func AuditLogAutocomplete(state *state.State, kvs storage.KeyValueStore, event *gateway.InteractionCreateEvent, interaction *discord.AutocompleteInteraction) api.AutocompleteChoices {
+	choices := api.AutocompleteStringChoices{}
+	found, value := autocomplete.GetAutocompleteValue(interaction)
+	if !found {
+		return choices
+	}
+	typed := strings.ToLower(strings.ReplaceAll(value.String(), "\"", ""))
+	features, err := storage.AuditFeatures(kvs, event.GuildID)
+	if err != nil {
+		log.Printf("[%s] Error looking up audit log features: %s", event.GuildID, err)
+		return choices
+	}
+	for _, feature := range features {
+		if strings.HasPrefix(feature, typed) && len(choices) < maxAutocompleteChoices {
+			choices = append(choices, discord.StringChoice{Name: feature, Value: feature})
+		}
+	}
+	return choices
+}
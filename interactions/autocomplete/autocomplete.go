@@ -1,6 +1,8 @@
 package autocomplete
 
 import (
+	"fmt"
+	"komainu/interactions/middleware"
 	"komainu/storage"
 	"log"
 
@@ -12,7 +14,8 @@ import (
 )
 
 type Handler struct {
-	Code HandlerFunction
+	Code        HandlerFunction
+	Middlewares []middleware.Middleware // Run after the ones every autocompletion goes through.
 }
 
 type HandlerFunction func(
@@ -24,6 +27,15 @@ type HandlerFunction func(
 
 var autocompleters = map[string]Handler{}
 
+// middlewares are what every autocompletion goes through before its own, outermost first.
+// There's no throttling, as autocompletion comes with every key pressed.
+var middlewares = []middleware.Middleware{
+	middleware.ReportErrors,
+	middleware.Timing,
+	middleware.Recover,
+	middleware.GuildOnly,
+}
+
 func Register(name string, handler Handler) {
 	autocompleters[name] = handler
 }
@@ -42,13 +54,10 @@ func AddHandler(state *state.State, kvs storage.KeyValueStore) {
 	state.AddHandler(func(e *gateway.InteractionCreateEvent) {
 		if interaction, ok := e.Data.(*discord.AutocompleteInteraction); ok {
 			if val, ok := autocompleters[interaction.Name]; ok {
-				response := val.Code(state, kvs, e, interaction)
-				state.RespondInteraction(e.ID, e.Token, api.InteractionResponse{
-					Type: api.AutocompleteResult,
-					Data: &api.InteractionResponseData{
-						Choices: response,
-					},
-				})
+				ctx := &middleware.Context{State: state, KVS: kvs, Event: e, Kind: middleware.AutocompleteKind, Name: interaction.Name}
+				middleware.Run(ctx, func(ctx *middleware.Context) error {
+					return val.handle(ctx, interaction)
+				}, middlewares, val.Middlewares)
 			} else {
 				state.RespondInteraction(e.ID, e.Token, api.InteractionResponse{
 					Type: api.AutocompleteResult,
@@ -59,3 +68,18 @@ func AddHandler(state *state.State, kvs storage.KeyValueStore) {
 		}
 	})
 }
+
+// handle runs the handler itself, once the middlewares are done with it.
+func (val Handler) handle(ctx *middleware.Context, interaction *discord.AutocompleteInteraction) error {
+	choices := val.Code(ctx.State, ctx.KVS, ctx.Event, interaction)
+	err := ctx.State.RespondInteraction(ctx.Event.ID, ctx.Event.Token, api.InteractionResponse{
+		Type: api.AutocompleteResult,
+		Data: &api.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not send choices: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package interactions
+
+import (
+	"errors"
+	"komainu/interactions/command"
+	"komainu/interactions/middleware"
+	"komainu/interactions/response"
+	"komainu/storage"
+	"log"
+	"path/filepath"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/state"
+)
+
+func init() {
+	command.Register("backup", commandBackupObject)
+}
+
+var commandBackupObject = command.Handler{
+	Description: "Back up the whole database right now (bot owner only)",
+	Code:        CommandBackup,
+	Access:      command.AdminAccess,
+	Deferral:    command.DeferredEphemeral,
+	Middlewares: []middleware.Middleware{command.OwnerOnly},
+	Options:     []discord.CommandOption{},
+}
+
+// CommandBackup processes the owner-only command to take a backup on demand.
+func CommandBackup(state *state.State, kvs storage.KeyValueStore, event *gateway.InteractionCreateEvent, cmd *discord.CommandInteraction) command.Response {
+	path, err := storage.BackupNow(kvs, storage.CurrentConfiguration().Backup)
+	if errors.Is(err, storage.ErrBackupUnsupported) {
+		return command.Response{Response: response.Ephemeral("The storage I'm running on can't be backed up. Is this a dev session?")}
+	}
+	if err != nil && path == "" {
+		log.Printf("[%s] /backup failed: %s", event.GuildID, err)
+		return command.Response{Response: response.Ephemeral("The backup failed! The error has been logged.")}
+	}
+	if err != nil {
+		log.Printf("[%s] /backup worked, but rotating old backups failed: %s", event.GuildID, err)
+		return command.Response{Response: response.Ephemeral("Backed up to `" + filepath.Base(path) + "`, but cleaning up old backups failed. The error has been logged.")}
+	}
+	log.Printf("[%s] <@%s> took a backup", event.GuildID, event.SenderID())
+	return command.Response{Response: response.Ephemeral("Backed up to `" + filepath.Base(path) + "`")}
+}
@@ -1,9 +1,9 @@
 package command
 
 import (
-	"komainu/interactions/response"
+	"fmt"
+	"komainu/interactions/middleware"
 	"komainu/storage"
-	"komainu/utility"
 	"log"
 
 	"github.com/diamondburned/arikawa/v3/api"
@@ -21,8 +21,9 @@ type Command func(
 ) Response
 
 type Response struct {
-	Response api.InteractionResponse
-	Callback func(message *discord.Message)
+	Response  api.InteractionResponse
+	Callback  func(message *discord.Message)
+	FollowUps []api.InteractionResponseData // Sent as messages of their own, after the response.
 }
 
 // IsEphemeral checks if the contained InteractionResponse is only shown to the user initiating the interaction.
@@ -44,88 +45,159 @@ type Handler struct {
 	Code        Command
 	Type        discord.CommandType
 	Options     []discord.CommandOption
+	Access      Access
+	Deferral    Deferral
+	Cooldown    Cooldown
+	Middlewares []middleware.Middleware // Run after the ones every command goes through.
+}
+
+// Access says who can use a command. It sets who Discord shows the command to, unless the guild overrides it,
+// and the command is refused for anyone without the matching permission no matter what the guild overrides say.
+// The zero value is AdminAccess, so a command that doesn't say stays locked down.
+type Access int
+
+const (
+	AdminAccess     Access = iota // Only those with the Administrator permission.
+	ModeratorAccess               // Those who can manage messages.
+	EveryoneAccess                // Anyone at all.
+)
+
+// permissions is what someone needs to use a command with this Access. Zero means nothing is needed.
+func (access Access) permissions() discord.Permissions {
+	switch access {
+	case EveryoneAccess:
+		return 0
+	case ModeratorAccess:
+		return discord.PermissionManageMessages
+	default:
+		return discord.PermissionAdministrator
+	}
+}
+
+// defaultMemberPermissions is what a command with this Access is registered with. Nil lets everyone use it,
+// and no permissions at all leaves it to administrators.
+func (access Access) defaultMemberPermissions() *discord.Permissions {
+	switch access {
+	case EveryoneAccess:
+		return nil
+	case ModeratorAccess:
+		return discord.NewPermissions(discord.PermissionManageMessages)
+	default:
+		return discord.NewPermissions(0)
+	}
+}
+
+// HasAccess checks if the sender of the interaction has the permissions the given Access asks for, in the channel it was sent from.
+func HasAccess(state *state.State, event *gateway.InteractionCreateEvent, access Access) (bool, error) {
+	needed := access.permissions()
+	if needed == 0 {
+		return true, nil
+	}
+	permissions, err := state.Permissions(event.ChannelID, event.SenderID())
+	if err != nil {
+		return false, err
+	}
+	return permissions.Has(needed) || permissions.Has(discord.PermissionAdministrator), nil
 }
 
 // commands holds the Commands to be registered with each joined guild.
 var commands = map[string]Handler{}
 
-// The Token Bins. 5 and 10 are arbituary numbers, and it decrements at 10 second intervals.
-var userTokenBin = &utility.TokenBin{Max: 5, Interval: 10}
-var channelTokenBin = &utility.TokenBin{Max: 10, Interval: 10}
+// cooldowns holds the settings for the cooldown of each command that has one.
+var cooldowns = map[string]cooldownSettings{}
+
+// middlewares are what every command goes through before its own, outermost first.
+var middlewares = []middleware.Middleware{
+	middleware.ReportErrors,
+	middleware.Timing,
+	middleware.Recover,
+	middleware.GuildOnly,
+	middleware.Throttle,
+}
 
 func Register(name string, command Handler) {
 	commands[name] = command
+	cooldowns[name] = registerCooldown(name, command.Cooldown)
 }
 
 // AddHandler adds handler for commands. You might have guessed that, but here we are.
 func AddHandler(state *state.State, kvs storage.KeyValueStore) {
 	state.AddHandler(func(e *gateway.InteractionCreateEvent) {
 		if interaction, ok := e.Data.(*discord.CommandInteraction); ok {
-			if e.GuildID == discord.NullGuildID || e.Member == nil { // Command issued in private
-				state.RespondInteraction(e.ID, e.Token, response.Ephemeral("I'm sorry, I do not respond to commands in private."))
-				return
-			}
-			if !userTokenBin.Allocate(discord.Snowflake(e.GuildID), discord.Snowflake(e.Member.User.ID)) {
-				if err := state.RespondInteraction(e.ID, e.Token, response.Ephemeral("You are using too many commands too quickly. Calm down.")); err != nil {
-					log.Println("An error occured posting throttle warning emphemral response (user):", err)
-				}
-				return
-			}
-			if !channelTokenBin.Allocate(discord.Snowflake(e.GuildID), discord.Snowflake(e.ChannelID)) {
-				if err := state.RespondInteraction(e.ID, e.Token, response.Ephemeral("Too many commands being processed in this channel right now. Please wait.")); err != nil {
-					log.Println("An error occured posting throttle warning emphemral response (channel):", err)
-				}
-				return
-			}
-
 			if val, ok := commands[interaction.Name]; ok {
-				resp := val.Code(state, kvs, e, interaction)
-
-				if resp.Length() > 1500 {
-					if resp.IsEphemeral() {
-						resp.Response.Data.Content = option.NewNullableString(resp.Response.Data.Content.Val)
-					}
-				}
-
-				if err := state.RespondInteraction(e.ID, e.Token, resp.Response); err != nil {
-					log.Printf("[%s] Failed to send command interaction response: %s", e.GuildID, err)
-				}
-				if resp.Callback != nil {
-					message, err := state.InteractionResponse(e.AppID, e.Token)
-					if err != nil {
-						log.Printf("Error %s getting message reference for %s command callback\n", err, interaction.Name)
-						return
-					}
-					if message != nil && message.ID != discord.NullMessageID {
-						resp.Callback(message)
-					}
-				}
+				ctx := &middleware.Context{State: state, KVS: kvs, Event: e, Kind: middleware.CommandKind, Name: interaction.Name}
+				middleware.Run(ctx, func(ctx *middleware.Context) error {
+					return val.handle(ctx, interaction)
+				}, middlewares, []middleware.Middleware{requireAccess(val.Access), requireCooldown(val.Cooldown, cooldowns[interaction.Name])}, val.Middlewares)
 			}
 		}
 	})
 }
 
-// RegisterCommands chews up the commands registered for the bot and actually registers them with Discord.
-func RegisterCommands(state *state.State) error {
-	app, err := state.CurrentApplication()
+// handle runs the command itself, once the middlewares are done with it.
+func (val Handler) handle(ctx *middleware.Context, interaction *discord.CommandInteraction) error {
+	ack, err := Acknowledge(ctx.State, ctx.Event, val.Deferral)
 	if err != nil {
-		return err
+		log.Printf("[%s] Failed to acknowledge /%s, so responding without deferring: %s", ctx.Event.GuildID, interaction.Name, err)
 	}
-	adminOnly := discord.NewPermissions(0)
-	bulkCommands := []api.CreateCommandData{}
-	for name, data := range commands {
-		bulkCommands = append(bulkCommands, api.CreateCommandData{
-			Name:                     name,
-			Description:              data.Description,
-			Options:                  data.Options,
-			Type:                     data.Type,
-			DefaultMemberPermissions: adminOnly,
-		})
+	resp := val.Code(ctx.State, ctx.KVS, ctx.Event, interaction)
+
+	if resp.Length() > 1500 {
+		if resp.IsEphemeral() {
+			resp.Response.Data.Content = option.NewNullableString(resp.Response.Data.Content.Val)
+		}
 	}
-	registered, err := state.BulkOverwriteCommands(app.ID, bulkCommands)
-	if err != nil {
-		return err
+
+	if err := Deliver(ctx.State, ctx.Event, ack, resp); err != nil {
+		return fmt.Errorf("could not send response: %w", err)
 	}
-	log.Printf("%d commands successfully registered", len(registered))
 	return nil
 }
+
+// requireAccess refuses the command to anyone without the permissions the given Access asks for.
+func requireAccess(access Access) middleware.Middleware {
+	return func(ctx *middleware.Context, next middleware.Handle) error {
+		allowed, err := HasAccess(ctx.State, ctx.Event, access)
+		if err != nil {
+			log.Printf("[%s] Failed to check permissions of <@%s> for /%s: %s", ctx.Event.GuildID, ctx.Event.SenderID(), ctx.Name, err)
+		}
+		if !allowed {
+			log.Printf("[%s] <@%s> tried to use /%s without the permissions for it", ctx.Event.GuildID, ctx.Event.SenderID(), ctx.Name)
+			return ctx.Refuse("Sorry, you don't have the permissions needed for that.")
+		}
+		return next(ctx)
+	}
+}
+
+// OwnerOnly refuses the command to anyone but the owners of the bot, see IsOwner.
+func OwnerOnly(ctx *middleware.Context, next middleware.Handle) error {
+	if !IsOwner(ctx.State, ctx.Event.SenderID()) {
+		log.Printf("[%s] <@%s> tried to use /%s, but is not the bot owner", ctx.Event.GuildID, ctx.Event.SenderID(), ctx.Name)
+		return ctx.Refuse("Sorry, only the owner of the bot can do that.")
+	}
+	return next(ctx)
+}
+
+// IsOwner checks if the given user owns the bot application, either directly or as part of the owning team.
+// Anyone listed as an owner in the configuration counts too.
+func IsOwner(state *state.State, userID discord.UserID) bool {
+	if cfg := storage.CurrentConfiguration(); cfg.IsOwner(userID) {
+		return true
+	}
+	app, err := state.CurrentApplication()
+	if err != nil {
+		log.Printf("Could not look up the current application to check ownership: %s", err)
+		return false
+	}
+	if app.Owner != nil && app.Owner.ID == userID {
+		return true
+	}
+	if app.Team != nil {
+		for _, member := range app.Team.Members {
+			if member.User.ID == userID {
+				return true
+			}
+		}
+	}
+	return false
+}
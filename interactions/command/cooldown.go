@@ -0,0 +1,128 @@
+package command
+
+import (
+	"fmt"
+	"komainu/interactions/middleware"
+	"komainu/storage"
+	"komainu/utility"
+	"log"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// CooldownScope says who shares a cooldown.
+type CooldownScope int
+
+const (
+	NoCooldown CooldownScope = iota
+	PerUser                  // Each user has a cooldown of their own.
+	PerChannel               // Everyone in a channel shares the cooldown.
+	PerGuild                 // Everyone in the guild shares the cooldown.
+)
+
+// Cooldown limits how often a command can be used, on top of the throttling every command goes through.
+// Guild admins can change the Duration and DailyCap with the <command>.cooldown and <command>.dailycap settings.
+type Cooldown struct {
+	Per      CooldownScope
+	Duration time.Duration // How long after being used the command can be used again. Zero means right away.
+	DailyCap int           // How many times a day the command can be used, counting from midnight UTC. Zero means no cap.
+}
+
+// cooldownSettings are the settings guild admins change a cooldown with.
+type cooldownSettings struct {
+	duration *storage.Setting[time.Duration]
+	dailyCap *storage.Setting[int]
+}
+
+// cooldownKey picks a bucket in the cooldown token bin.
+type cooldownKey struct {
+	Command string
+	Guild   discord.GuildID
+	ID      discord.Snowflake // The user, channel or guild, depending on the scope.
+}
+
+// cooldownTokenBin holds the cooldowns of every command, as buckets with a single token.
+var cooldownTokenBin = &utility.TokenBin[cooldownKey]{}
+
+// registerCooldown declares the settings for the cooldown of the named command, if it has one.
+func registerCooldown(name string, cooldown Cooldown) (settings cooldownSettings) {
+	if cooldown.Per == NoCooldown {
+		return
+	}
+	settings.duration = storage.DurationSetting(name+".cooldown",
+		fmt.Sprintf("How long until /%s can be used again %s. 0s turns it off", name, cooldown.Per), cooldown.Duration)
+	settings.dailyCap = storage.NumberSetting(name+".dailycap",
+		fmt.Sprintf("How many times a day /%s can be used %s. 0 means no cap", name, cooldown.Per), cooldown.DailyCap)
+	return
+}
+
+// String describes who shares the cooldown, as the end of a sentence.
+func (scope CooldownScope) String() string {
+	switch scope {
+	case PerUser:
+		return "by each user"
+	case PerChannel:
+		return "in each channel"
+	case PerGuild:
+		return "in this guild"
+	default:
+		return "by anyone"
+	}
+}
+
+// id picks what the cooldown is kept for, out of the interaction.
+func (scope CooldownScope) id(ctx *middleware.Context) discord.Snowflake {
+	switch scope {
+	case PerUser:
+		return discord.Snowflake(ctx.Event.SenderID())
+	case PerChannel:
+		return discord.Snowflake(ctx.Event.ChannelID)
+	default:
+		return discord.Snowflake(ctx.Event.GuildID)
+	}
+}
+
+// requireCooldown refuses the command while it is cooling down, or when it has been used as often as it can today.
+// The limits are looked up every time, so changes to the settings take effect right away.
+func requireCooldown(cooldown Cooldown, settings cooldownSettings) middleware.Middleware {
+	return func(ctx *middleware.Context, next middleware.Handle) error {
+		if cooldown.Per == NoCooldown {
+			return next(ctx)
+		}
+		guildID := ctx.Event.GuildID
+		duration, _, err := settings.duration.Get(ctx.KVS, guildID)
+		if err != nil {
+			log.Printf("[%s] Failed to look up the cooldown of /%s, so using the default: %s", guildID, ctx.Name, err)
+			duration = cooldown.Duration
+		}
+		dailyCap, _, err := settings.dailyCap.Get(ctx.KVS, guildID)
+		if err != nil {
+			log.Printf("[%s] Failed to look up the daily cap of /%s, so using the default: %s", guildID, ctx.Name, err)
+			dailyCap = cooldown.DailyCap
+		}
+
+		id := cooldown.Per.id(ctx)
+		key := cooldownKey{ctx.Name, guildID, id}
+		if duration > 0 {
+			if ok, wait := cooldownTokenBin.Allocate(key, utility.Limit{Max: 1, Interval: duration}); !ok {
+				return ctx.Refuse(fmt.Sprintf("/%s is cooling down. It can be used again in %s.", ctx.Name, middleware.RoundWait(wait)))
+			}
+		}
+		if dailyCap > 0 {
+			ok, wait, err := storage.UseDaily(ctx.KVS, guildID, fmt.Sprintf("%s/%s", ctx.Name, id), dailyCap)
+			if duration > 0 && (err != nil || !ok) {
+				// The command isn't going to run, so it shouldn't start cooling down either.
+				cooldownTokenBin.Refund(key)
+			}
+			if err != nil {
+				return fmt.Errorf("could not count the daily use: %w", err)
+			}
+			if !ok {
+				return ctx.Refuse(fmt.Sprintf("/%s can only be used %d times a day %s. It can be used again in %s.",
+					ctx.Name, dailyCap, cooldown.Per, middleware.RoundWait(wait)))
+			}
+		}
+		return next(ctx)
+	}
+}
@@ -0,0 +1,105 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/state"
+)
+
+// Deferral says if an interaction is acknowledged before its handler runs, for handlers that can take longer than the three seconds Discord allows.
+// A deferred handler can't respond with a modal.
+type Deferral int
+
+const (
+	NotDeferred       Deferral = iota
+	Deferred                   // Everyone sees the bot thinking, until the response replaces it.
+	DeferredEphemeral          // Only the user sees the bot thinking, until the response replaces it.
+)
+
+// Acknowledgement is what Acknowledge told Discord, so Deliver knows how to follow up on it.
+type Acknowledgement struct {
+	Type      api.InteractionResponseType // Zero if the interaction wasn't acknowledged.
+	Ephemeral bool
+}
+
+// Acknowledge tells Discord the interaction is being worked on, if the deferral asks for it.
+// Components are acknowledged without showing anything, as the response may update the message they are on rather than make a new one.
+func Acknowledge(state *state.State, event *gateway.InteractionCreateEvent, deferral Deferral) (Acknowledgement, error) {
+	if deferral == NotDeferred {
+		return Acknowledgement{}, nil
+	}
+	ack := Acknowledgement{Type: api.DeferredMessageInteractionWithSource, Ephemeral: deferral == DeferredEphemeral}
+	if _, ok := event.Data.(discord.ComponentInteraction); ok {
+		ack = Acknowledgement{Type: api.DeferredMessageUpdate}
+	}
+	resp := api.InteractionResponse{Type: ack.Type}
+	if ack.Ephemeral {
+		resp.Data = &api.InteractionResponseData{Flags: api.EphemeralResponse}
+	}
+	if err := state.RespondInteraction(event.ID, event.Token, resp); err != nil {
+		return Acknowledgement{}, err
+	}
+	return ack, nil
+}
+
+// Deliver sends the response to an interaction, finishing what Acknowledge started if anything.
+// A deferred response is edited into the thinking message when it fits there, and sent as a follow-up when it doesn't,
+// like an ephemeral response to a thinking message everyone can see. Any FollowUps are sent after it, and then the Callback is called.
+func Deliver(state *state.State, event *gateway.InteractionCreateEvent, ack Acknowledgement, resp Response) error {
+	message, err := deliver(state, event, ack, resp.Response)
+	if err != nil {
+		return err
+	}
+	for _, followUp := range resp.FollowUps {
+		if _, err := state.CreateInteractionFollowup(event.AppID, event.Token, followUp); err != nil {
+			return fmt.Errorf("could not send follow-up: %w", err)
+		}
+	}
+	if resp.Callback == nil {
+		return nil
+	}
+	if message == nil {
+		if message, err = state.InteractionResponse(event.AppID, event.Token); err != nil {
+			return fmt.Errorf("could not get message reference for callback: %w", err)
+		}
+	}
+	if message != nil && message.ID != discord.NullMessageID {
+		resp.Callback(message)
+	}
+	return nil
+}
+
+// deliver sends the response itself. The message sent is returned when Discord hands it back anyway.
+func deliver(state *state.State, event *gateway.InteractionCreateEvent, ack Acknowledgement, resp api.InteractionResponse) (*discord.Message, error) {
+	if ack.Type == 0 {
+		return nil, state.RespondInteraction(event.ID, event.Token, resp)
+	}
+	if resp.Type == api.ModalResponse {
+		return nil, errors.New("a deferred interaction can't respond with a modal")
+	}
+	if resp.Data == nil {
+		return nil, nil // Acknowledging was all there was to it.
+	}
+	ephemeral := resp.Data.Flags&api.EphemeralResponse != 0
+	fitsAcknowledgement := (resp.Type == api.UpdateMessage && ack.Type == api.DeferredMessageUpdate) ||
+		(resp.Type == api.MessageInteractionWithSource && ack.Type == api.DeferredMessageInteractionWithSource && ephemeral == ack.Ephemeral)
+	if fitsAcknowledgement {
+		return state.EditInteractionResponse(event.AppID, event.Token, api.EditInteractionResponseData{
+			Content:         resp.Data.Content,
+			Embeds:          resp.Data.Embeds,
+			Components:      resp.Data.Components,
+			AllowedMentions: resp.Data.AllowedMentions,
+			Files:           resp.Data.Files,
+		})
+	}
+	if ack.Type == api.DeferredMessageInteractionWithSource {
+		if err := state.DeleteInteractionResponse(event.AppID, event.Token); err != nil {
+			return nil, fmt.Errorf("could not remove the thinking message: %w", err)
+		}
+	}
+	return state.CreateInteractionFollowup(event.AppID, event.Token, *resp.Data)
+}
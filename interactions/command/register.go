@@ -0,0 +1,192 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"komainu/storage"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// CommandDiff lists, by name, how the commands registered with Discord differ from the ones the bot has.
+type CommandDiff struct {
+	Added   []string
+	Changed []string
+	Removed []string
+}
+
+// Empty checks if there are no differences at all.
+func (diff CommandDiff) Empty() bool {
+	return len(diff.Added) == 0 && len(diff.Changed) == 0 && len(diff.Removed) == 0
+}
+
+// String describes the differences in a single line, suitable for the log.
+func (diff CommandDiff) String() string {
+	if diff.Empty() {
+		return "no changes"
+	}
+	parts := []string{}
+	for _, group := range []struct {
+		label string
+		names []string
+	}{{"added", diff.Added}, {"changed", diff.Changed}, {"removed", diff.Removed}} {
+		if len(group.names) > 0 {
+			parts = append(parts, fmt.Sprintf("%s %s", group.label, strings.Join(group.names, ", ")))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// desiredCommands turns the registered Handlers into what Discord wants to be told, sorted by name.
+func desiredCommands() []api.CreateCommandData {
+	desired := []api.CreateCommandData{}
+	for name, data := range commands {
+		desired = append(desired, api.CreateCommandData{
+			Name:                     name,
+			Description:              data.Description,
+			Options:                  data.Options,
+			Type:                     data.Type,
+			DefaultMemberPermissions: data.Access.defaultMemberPermissions(),
+		})
+	}
+	sort.Slice(desired, func(i, j int) bool { return desired[i].Name < desired[j].Name })
+	return desired
+}
+
+// diffCommands compares the desired commands with those already registered.
+func diffCommands(desired []api.CreateCommandData, registered []discord.Command) (diff CommandDiff) {
+	existing := map[string]discord.Command{}
+	for _, cmd := range registered {
+		existing[cmd.Name] = cmd
+	}
+	for _, want := range desired {
+		have, ok := existing[want.Name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, want.Name)
+		case commandChanged(want, have):
+			diff.Changed = append(diff.Changed, want.Name)
+		}
+		delete(existing, want.Name)
+	}
+	for name := range existing {
+		diff.Removed = append(diff.Removed, name)
+	}
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+// commandChanged checks if a registered command differs from what it should be.
+// The options are compared by their JSON, which is what Discord sees anyway, once normalised by optionsJSON.
+func commandChanged(want api.CreateCommandData, have discord.Command) bool {
+	wantType := want.Type
+	if wantType == 0 {
+		wantType = discord.ChatInputCommand // What Discord assumes when it isn't given.
+	}
+	if wantType != have.Type || want.Description != have.Description {
+		return true
+	}
+	if (want.DefaultMemberPermissions == nil) != (have.DefaultMemberPermissions == nil) ||
+		(want.DefaultMemberPermissions != nil && *want.DefaultMemberPermissions != *have.DefaultMemberPermissions) {
+		return true
+	}
+	wantOptions, wantErr := optionsJSON(want.Options)
+	haveOptions, haveErr := optionsJSON(have.Options)
+	return wantErr != nil || haveErr != nil || wantOptions != haveOptions
+}
+
+// optionsJSON encodes command options with the nulls and empty lists left out.
+// Discord leaves out what is empty, so an empty list of sub-options comes back as nothing at all, which encodes as null.
+func optionsJSON(options discord.CommandOptions) (string, error) {
+	if len(options) == 0 {
+		return "[]", nil
+	}
+	raw, err := json.Marshal(options)
+	if err != nil {
+		return "", err
+	}
+	var decoded any
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return "", err
+	}
+	normalised, err := json.Marshal(pruneEmptyJSON(decoded))
+	return string(normalised), err
+}
+
+// pruneEmptyJSON removes the object fields that are null or an empty list, all the way down.
+func pruneEmptyJSON(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, field := range typed {
+			if list, isList := field.([]any); field == nil || (isList && len(list) == 0) {
+				delete(typed, key)
+				continue
+			}
+			typed[key] = pruneEmptyJSON(field)
+		}
+	case []any:
+		for i, item := range typed {
+			typed[i] = pruneEmptyJSON(item)
+		}
+	}
+	return value
+}
+
+// RegisterCommands compares the commands the bot has with those registered with Discord, logs the differences, and then makes Discord match.
+// In dev mode, commands are registered with each of the configured DevGuildIDs instead of globally,
+// as that takes effect right away and leaves the commands everyone else uses alone. With dryRun set, the differences are only logged.
+func RegisterCommands(client *api.Client, cfg *storage.Configuration, dryRun bool) error {
+	app, err := client.CurrentApplication()
+	if err != nil {
+		return err
+	}
+	desired := desiredCommands()
+	if !cfg.DevMode {
+		return syncCommands("globally", desired, dryRun,
+			func() ([]discord.Command, error) { return client.Commands(app.ID) },
+			func(cmds []api.CreateCommandData) ([]discord.Command, error) {
+				return client.BulkOverwriteCommands(app.ID, cmds)
+			},
+		)
+	}
+	if len(cfg.DevGuildIDs) == 0 {
+		log.Println("Dev mode is on, but there are no dev guilds to register commands with, so none were registered")
+		return nil
+	}
+	for _, guildID := range cfg.DevGuildIDs {
+		guildID := guildID
+		err := syncCommands(fmt.Sprintf("with dev guild %s", guildID), desired, dryRun,
+			func() ([]discord.Command, error) { return client.GuildCommands(app.ID, guildID) },
+			func(cmds []api.CreateCommandData) ([]discord.Command, error) {
+				return client.BulkOverwriteGuildCommands(app.ID, guildID, cmds)
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncCommands diffs the desired commands against the registered ones, and overwrites them if there are any differences.
+func syncCommands(where string, desired []api.CreateCommandData, dryRun bool, registered func() ([]discord.Command, error), overwrite func([]api.CreateCommandData) ([]discord.Command, error)) error {
+	current, err := registered()
+	if err != nil {
+		return fmt.Errorf("could not get the commands registered %s: %w", where, err)
+	}
+	diff := diffCommands(desired, current)
+	log.Printf("Commands registered %s: %s", where, diff)
+	if diff.Empty() || dryRun {
+		return nil
+	}
+	result, err := overwrite(desired)
+	if err != nil {
+		return fmt.Errorf("could not register commands %s: %w", where, err)
+	}
+	log.Printf("%d commands successfully registered %s", len(result), where)
+	return nil
+}
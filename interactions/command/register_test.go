@@ -0,0 +1,66 @@
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// registeredCommands decodes commands the way Discord hands them back, leaving out whatever is empty.
+const registeredCommands = `[
+	{"id": "1", "application_id": "2", "type": 1, "name": "config", "description": "View and change the settings",
+		"default_member_permissions": "0",
+		"options": [{"type": 1, "name": "view", "description": "Show every setting"}, {"type": 1, "name": "list", "description": "List them"}]},
+	{"id": "3", "application_id": "2", "type": 1, "name": "faq", "description": "Look up a topic"},
+	{"id": "4", "application_id": "2", "type": 1, "name": "retired", "description": "Gone now"}
+]`
+
+func TestDiffCommands(t *testing.T) {
+	var registered []discord.Command
+	if err := json.NewDecoder(strings.NewReader(registeredCommands)).Decode(&registered); err != nil {
+		t.Errorf("Could not decode the registered commands: %v", err)
+		return
+	}
+	desired := []api.CreateCommandData{
+		{
+			Name:                     "config",
+			Description:              "View and change the settings",
+			DefaultMemberPermissions: AdminAccess.defaultMemberPermissions(),
+			Options: []discord.CommandOption{
+				&discord.SubcommandOption{
+					OptionName:  "view",
+					Description: "Show every setting",
+					Options:     []discord.CommandOptionValue{},
+				},
+				&discord.SubcommandOption{
+					OptionName:  "list",
+					Description: "List them",
+				},
+			},
+		},
+		{Name: "faq", Description: "Look up a topic, quickly"},
+		{Name: "seen", Description: "Check when someone was last around"},
+	}
+
+	diff := diffCommands(desired, registered)
+	if strings.Join(diff.Added, ",") != "seen" {
+		t.Errorf("Expected seen to be added, Got %v", diff.Added)
+	}
+	if strings.Join(diff.Changed, ",") != "faq" {
+		t.Errorf("Expected only faq to be changed, as empty and missing lists of sub-options are the same, Got %v", diff.Changed)
+	}
+	if strings.Join(diff.Removed, ",") != "retired" {
+		t.Errorf("Expected retired to be removed, Got %v", diff.Removed)
+	}
+
+	if diff := diffCommands(desired[:1], registered[:1]); !diff.Empty() {
+		t.Errorf("Expected no differences, Got %s", diff)
+	}
+	desired[0].DefaultMemberPermissions = ModeratorAccess.defaultMemberPermissions()
+	if diff := diffCommands(desired[:1], registered[:1]); strings.Join(diff.Changed, ",") != "config" {
+		t.Errorf("Expected a change of access to count as a change, Got %s", diff)
+	}
+}
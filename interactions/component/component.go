@@ -1,6 +1,9 @@
 package component
 
 import (
+	"fmt"
+	"komainu/interactions/command"
+	"komainu/interactions/middleware"
 	"komainu/interactions/response"
 	"komainu/storage"
 	"log"
@@ -13,7 +16,9 @@ import (
 )
 
 type Handler struct {
-	Code HandlerFunction
+	Code        HandlerFunction
+	Deferral    command.Deferral        // Any deferral acknowledges without showing anything, see command.Acknowledge.
+	Middlewares []middleware.Middleware // Run after the ones every component interaction goes through.
 }
 
 type HandlerFunction func(
@@ -25,6 +30,14 @@ type HandlerFunction func(
 
 var registrations = map[string]Handler{}
 
+// middlewares are what every component interaction goes through before its own, outermost first.
+var middlewares = []middleware.Middleware{
+	middleware.ReportErrors,
+	middleware.Timing,
+	middleware.Recover,
+	middleware.GuildOnly,
+}
+
 // Register sets what function should handle interactions on the given message.
 func Register(identifier string, handler Handler) {
 	registrations[identifier] = handler
@@ -37,10 +50,10 @@ func AddHandler(state *state.State, kvs storage.KeyValueStore) {
 			target := strings.SplitN(string(interaction.ID()), "/", 2)[0]
 
 			if handler, ok := registrations[target]; ok {
-				resp := handler.Code(state, kvs, e, interaction)
-				if err := state.RespondInteraction(e.ID, e.Token, resp); err != nil {
-					log.Printf("[%s] Failed to send component interaction response: %s", e.GuildID, err)
-				}
+				ctx := &middleware.Context{State: state, KVS: kvs, Event: e, Kind: middleware.ComponentKind, Name: target}
+				middleware.Run(ctx, func(ctx *middleware.Context) error {
+					return handler.handle(ctx, interaction)
+				}, middlewares, handler.Middlewares)
 			} else {
 				log.Printf("[%s] Got a %q component interaction, but there is no registered handler!", e.GuildID, target)
 				if err := state.RespondInteraction(e.ID, e.Token, response.Ephemeral("Something odd happened. It has been logged.")); err != nil {
@@ -50,3 +63,16 @@ func AddHandler(state *state.State, kvs storage.KeyValueStore) {
 		}
 	})
 }
+
+// handle runs the handler itself, once the middlewares are done with it.
+func (handler Handler) handle(ctx *middleware.Context, interaction discord.ComponentInteraction) error {
+	ack, err := command.Acknowledge(ctx.State, ctx.Event, handler.Deferral)
+	if err != nil {
+		log.Printf("[%s] Failed to acknowledge %q component interaction, so responding without deferring: %s", ctx.Event.GuildID, ctx.Name, err)
+	}
+	resp := handler.Code(ctx.State, ctx.KVS, ctx.Event, interaction)
+	if err := command.Deliver(ctx.State, ctx.Event, ack, command.Response{Response: resp}); err != nil {
+		return fmt.Errorf("could not send response: %w", err)
+	}
+	return nil
+}
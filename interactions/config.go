@@ -0,0 +1,287 @@
+package interactions
+
+import (
+	"fmt"
+	"komainu/interactions/autocomplete"
+	"komainu/interactions/command"
+	"komainu/interactions/response"
+	"komainu/storage"
+	"log"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/state"
+)
+
+func init() {
+	command.Register("config", commandConfigObject)
+	autocomplete.Register("config", autocomplete.Handler{Code: ConfigAutocomplete})
+}
+
+var commandConfigObject = command.Handler{
+	Description: "View and change the settings for this guild",
+	Code:        CommandConfig,
+	Access:      command.AdminAccess,
+	Options: []discord.CommandOption{
+		&discord.SubcommandOption{
+			OptionName:  "view",
+			Description: "Show every setting, and what it is set to",
+			Options:     []discord.CommandOptionValue{},
+		},
+		&discord.SubcommandOption{
+			OptionName:  "set",
+			Description: "Change a setting",
+			Options: []discord.CommandOptionValue{
+				&discord.StringOption{
+					OptionName:   "setting",
+					Description:  "The setting to change",
+					Required:     true,
+					Autocomplete: true,
+				},
+				&discord.StringOption{
+					OptionName:   "value",
+					Description:  "What to change it to",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
+		&discord.SubcommandOption{
+			OptionName:  "reset",
+			Description: "Change a setting back to its default",
+			Options: []discord.CommandOptionValue{
+				&discord.StringOption{
+					OptionName:   "setting",
+					Description:  "The setting to reset",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
+	},
+}
+
+// CommandConfig processes the command for viewing and changing guild settings.
+func CommandConfig(state *state.State, kvs storage.KeyValueStore, event *gateway.InteractionCreateEvent, cmd *discord.CommandInteraction) command.Response {
+	if cmd.Options == nil || len(cmd.Options) != 1 {
+		log.Printf("[%s] /config command structure is somehow nil or not a single element. Wat.\n", event.GuildID)
+		return command.Response{Response: response.Ephemeral("I'm sorry, what? Something very weird happened.")}
+	}
+	switch cmd.Options[0].Name {
+	case "view":
+		return command.Response{Response: SubCommandConfigView(kvs, event.GuildID)}
+	case "set":
+		return command.Response{Response: SubCommandConfigSet(state, kvs, event.GuildID, event.SenderID(), cmd.Options[0].Options)}
+	case "reset":
+		return command.Response{Response: SubCommandConfigReset(state, kvs, event.GuildID, event.SenderID(), cmd.Options[0].Options)}
+	default:
+		return command.Response{Response: response.Ephemeral("Unknown subcommand! Clearly *someone* dropped the ball!")}
+	}
+}
+
+// SubCommandConfigView processes a subcommand to list every setting with its value.
+func SubCommandConfigView(kvs storage.KeyValueStore, guildID discord.GuildID) api.InteractionResponse {
+	var sb strings.Builder
+	fmt.Fprintln(&sb, "**Settings for this guild:**")
+	for _, setting := range storage.GuildSettings() {
+		shown, isSet, err := setting.Show(kvs, guildID)
+		if err != nil {
+			log.Printf("[%s] /config view failed to look up %s: %s", guildID, setting.Name(), err)
+			return response.Ephemeral("An error occured, and has been logged.")
+		}
+		if !isSet {
+			shown += " (default)"
+		}
+		fmt.Fprintf(&sb, "- `%s`: %s\n  %s\n", setting.Name(), shown, setting.Description())
+	}
+	return response.Ephemeral(sb.String())
+}
+
+// SubCommandConfigSet processes a subcommand to change a setting.
+func SubCommandConfigSet(state *state.State, kvs storage.KeyValueStore, guildID discord.GuildID, userID discord.UserID, options []discord.CommandInteractionOption) api.InteractionResponse {
+	setting, ok := lookupSettingOption(options)
+	if !ok {
+		return response.Ephemeral("There is no such setting. Try `/config view` to see them all.")
+	}
+	value := discord.CommandInteractionOptions(options).Find("value").String()
+	if problem := checkSettingTarget(state, guildID, setting, value); problem != "" {
+		return response.Ephemeral(fmt.Sprintf("Could not set `%s`: %s", setting.Name(), problem))
+	}
+	entry, err := settingAuditEntry(kvs, guildID, userID, setting, "set")
+	if err != nil {
+		log.Printf("[%s] /config set failed to look up %s: %s", guildID, setting.Name(), err)
+		return response.Ephemeral("An error occured, and has been logged.")
+	}
+	var invalid error
+	err = kvs.Batch(func(tx storage.Tx) error {
+		entry.New, invalid = setting.SetText(tx, guildID, value)
+		if invalid != nil {
+			return invalid
+		}
+		return storage.RecordAudit(tx, guildID, &entry)
+	})
+	if invalid != nil {
+		return response.Ephemeral(fmt.Sprintf("Could not set `%s`: %s", setting.Name(), invalid))
+	}
+	if err != nil {
+		log.Printf("[%s] /config set failed to set %s: %s", guildID, setting.Name(), err)
+		return response.Ephemeral("An error occured, and has been logged.")
+	}
+	log.Printf("[%s] <@%s> set %s to %s", guildID, userID, setting.Name(), entry.New)
+	mirrorAudit(state, kvs, guildID, entry)
+	return response.MessageNoMention(fmt.Sprintf("`%s` is now %s", setting.Name(), entry.New))
+}
+
+// SubCommandConfigReset processes a subcommand to change a setting back to its default.
+func SubCommandConfigReset(state *state.State, kvs storage.KeyValueStore, guildID discord.GuildID, userID discord.UserID, options []discord.CommandInteractionOption) api.InteractionResponse {
+	setting, ok := lookupSettingOption(options)
+	if !ok {
+		return response.Ephemeral("There is no such setting. Try `/config view` to see them all.")
+	}
+	entry, err := settingAuditEntry(kvs, guildID, userID, setting, "reset")
+	if err != nil {
+		log.Printf("[%s] /config reset failed to look up %s: %s", guildID, setting.Name(), err)
+		return response.Ephemeral("An error occured, and has been logged.")
+	}
+	err = kvs.Batch(func(tx storage.Tx) error {
+		if err := setting.Reset(tx, guildID); err != nil {
+			return err
+		}
+		return storage.RecordAudit(tx, guildID, &entry)
+	})
+	if err != nil {
+		log.Printf("[%s] /config reset failed to reset %s: %s", guildID, setting.Name(), err)
+		return response.Ephemeral("An error occured, and has been logged.")
+	}
+	shown, _, err := setting.Show(kvs, guildID)
+	if err != nil {
+		log.Printf("[%s] /config reset failed to look up %s: %s", guildID, setting.Name(), err)
+		return response.Ephemeral("An error occured, and has been logged.")
+	}
+	log.Printf("[%s] <@%s> reset %s", guildID, userID, setting.Name())
+	mirrorAudit(state, kvs, guildID, entry)
+	return response.MessageNoMention(fmt.Sprintf("`%s` is back to its default, %s", setting.Name(), shown))
+}
+
+// settingAuditEntry starts the audit entry for a change to a setting, with the value before the change.
+// Settings are named feature.something, so the feature is everything up to the first dot.
+func settingAuditEntry(kvs storage.KeyValueStore, guildID discord.GuildID, userID discord.UserID, setting storage.GuildSetting, action string) (entry storage.AuditEntry, err error) {
+	feature, _, _ := strings.Cut(setting.Name(), ".")
+	entry = storage.AuditEntry{UserID: userID, Feature: feature, Action: action, Target: setting.Name()}
+	old, isSet, err := setting.Show(kvs, guildID)
+	if isSet {
+		entry.Old = old
+	}
+	return entry, err
+}
+
+// lookupSettingOption finds the setting named in the options of a /config subcommand.
+func lookupSettingOption(options []discord.CommandInteractionOption) (storage.GuildSetting, bool) {
+	name := strings.ToLower(strings.TrimSpace(discord.CommandInteractionOptions(options).Find("setting").String()))
+	return storage.LookupSetting(name)
+}
+
+// checkSettingTarget checks that a channel or role setting points at something in this guild. Returns what is wrong, if anything.
+// Values that aren't IDs at all are left for the setting itself to complain about.
+func checkSettingTarget(state *state.State, guildID discord.GuildID, setting storage.GuildSetting, value string) string {
+	snowflake, err := discord.ParseSnowflake(strings.Trim(value, "<#@&>"))
+	if err != nil {
+		return ""
+	}
+	switch setting.Kind() {
+	case storage.SettingChannel:
+		channel, err := state.Channel(discord.ChannelID(snowflake))
+		if err != nil || channel.GuildID != guildID {
+			return "I can't find that channel here"
+		}
+	case storage.SettingRole:
+		if _, err := state.Role(guildID, discord.RoleID(snowflake)); err != nil {
+			return "I can't find that role here"
+		}
+	}
+	return ""
+}
+
+// ConfigAutocomplete suggests setting names, and values that fit the kind of setting chosen.
+func ConfigAutocomplete(state *state.State, kvs storage.KeyValueStore, event *gateway.InteractionCreateEvent, interaction *discord.AutocompleteInteraction) api.AutocompleteChoices {
+	choices := api.AutocompleteStringChoices{}
+	if len(interaction.Options) != 1 {
+		return choices
+	}
+	options := interaction.Options[0].Options
+	var focused discord.AutocompleteOption
+	for _, option := range options {
+		if option.Focused {
+			focused = option
+		}
+	}
+	typed := strings.ToLower(focused.String())
+
+	switch focused.Name {
+	case "setting":
+		for _, setting := range storage.GuildSettings() {
+			if strings.Contains(setting.Name(), typed) && len(choices) < maxAutocompleteChoices {
+				choices = append(choices, discord.StringChoice{Name: setting.Name(), Value: setting.Name()})
+			}
+		}
+	case "value":
+		setting, ok := storage.LookupSetting(strings.ToLower(discord.AutocompleteOptions(options).Find("setting").String()))
+		if !ok {
+			return choices
+		}
+		choices = settingValueChoices(state, event.GuildID, setting.Kind(), typed)
+	}
+	return choices
+}
+
+// settingValueChoices suggests values for a kind of setting, matching what has been typed so far.
+func settingValueChoices(state *state.State, guildID discord.GuildID, kind storage.SettingKind, typed string) api.AutocompleteStringChoices {
+	choices := api.AutocompleteStringChoices{}
+	add := func(name string, value string) {
+		if strings.Contains(strings.ToLower(name), typed) && len(choices) < maxAutocompleteChoices {
+			choices = append(choices, discord.StringChoice{Name: name, Value: value})
+		}
+	}
+	switch kind {
+	case storage.SettingChannel:
+		channels, err := state.Channels(guildID)
+		if err != nil {
+			log.Printf("[%s] Error looking up channels for /config autocomplete: %s", guildID, err)
+			return choices
+		}
+		for _, channel := range channels {
+			if channel.Type == discord.GuildText || channel.Type == discord.GuildNews {
+				add("#"+channel.Name, channel.ID.String())
+			}
+		}
+	case storage.SettingRole:
+		roles, err := state.Roles(guildID)
+		if err != nil {
+			log.Printf("[%s] Error looking up roles for /config autocomplete: %s", guildID, err)
+			return choices
+		}
+		for _, role := range roles {
+			if !role.Managed && discord.GuildID(role.ID) != guildID { // Skip bot roles and @everyone.
+				add("@"+role.Name, role.ID.String())
+			}
+		}
+	case storage.SettingBool:
+		add("on", "on")
+		add("off", "off")
+	case storage.SettingDuration:
+		for _, example := range []string{"1h", "12h", "1d", "7d", "30d", "90d"} {
+			add(example, example)
+		}
+	case storage.SettingNumber:
+		for _, example := range []string{"0", "5", "10", "25"} {
+			add(example, example)
+		}
+	case storage.SettingRate:
+		for _, example := range []string{"3/10s", "5/10s", "10/1m", "30/1h"} {
+			add(example, example)
+		}
+	}
+	return choices
+}
@@ -0,0 +1,55 @@
+package crash
+
+import (
+	"fmt"
+	"komainu/storage"
+	"log"
+	"runtime/debug"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/state"
+	"github.com/diamondburned/arikawa/v3/utils/sendpart"
+
+	"github.com/google/uuid"
+)
+
+// Report deals with a panic that was recovered while handling something, described by what.
+// The stack is logged with a new error ID, which is returned so the user can be told about it,
+// and posted to the configured error channel if there is one. Call it from the deferred function that recovered.
+func Report(state *state.State, guildID discord.GuildID, what string, recovered any) (errorID string) {
+	errorID = strings.SplitN(uuid.New().String(), "-", 2)[0]
+	stack := debug.Stack()
+	log.Printf("[%s] Error %s: panic handling %s: %v\n%s", guildID, errorID, what, recovered, stack)
+
+	channelID := storage.CurrentConfiguration().ErrorChannel
+	if !channelID.IsValid() {
+		return errorID
+	}
+	_, err := state.SendMessageComplex(channelID, api.SendMessageData{
+		Content: fmt.Sprintf("Error `%s` in guild %s, handling %s: %v", errorID, guildID, what, recovered),
+		Files: []sendpart.File{
+			{
+				Name:   "error_" + errorID + ".txt",
+				Reader: strings.NewReader(fmt.Sprintf("%v\n\n%s", recovered, stack)),
+			},
+		},
+		AllowedMentions: &api.AllowedMentions{
+			Parse: []api.AllowedMentionType{},
+		},
+	})
+	if err != nil {
+		log.Printf("[%s] Failed to post error %s to <#%s>: %s", guildID, errorID, channelID, err)
+	}
+	return errorID
+}
+
+// Recover recovers from a panic in a gateway event handler and reports it. There's nobody to tell, so that's it.
+// It has to be deferred directly, like: defer crash.Recover(state, event.GuildID, "message create event")
+// Dispatchers defer it around each handler on its own, so one panicking doesn't keep the rest from running.
+func Recover(state *state.State, guildID discord.GuildID, what string) {
+	if recovered := recover(); recovered != nil {
+		Report(state, guildID, what, recovered)
+	}
+}
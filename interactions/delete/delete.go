@@ -1,6 +1,7 @@
 package delete
 
 import (
+	"komainu/interactions/crash"
 	"komainu/storage"
 
 	"github.com/diamondburned/arikawa/v3/gateway"
@@ -33,7 +34,10 @@ func AddHandler(state *state.State, kvs storage.KeyValueStore) {
 	}
 	state.PreHandler.AddSyncHandler(func(event *gateway.MessageDeleteEvent) {
 		for _, handler := range deleteHandlers {
-			handler.Code(state, kvs, event)
+			func() {
+				defer crash.Recover(state, event.GuildID, "message delete event")
+				handler.Code(state, kvs, event)
+			}()
 		}
 	})
 }
@@ -1,6 +1,7 @@
 package edit
 
 import (
+	"komainu/interactions/crash"
 	"komainu/storage"
 
 	"github.com/diamondburned/arikawa/v3/gateway"
@@ -33,7 +34,10 @@ func AddHandler(state *state.State, kvs storage.KeyValueStore) {
 	}
 	state.PreHandler.AddSyncHandler(func(event *gateway.MessageUpdateEvent) {
 		for _, handler := range editHandlers {
-			handler.Code(state, kvs, event)
+			func() {
+				defer crash.Recover(state, event.GuildID, "message update event")
+				handler.Code(state, kvs, event)
+			}()
 		}
 	})
 }
@@ -19,6 +19,12 @@ import (
 	"github.com/diamondburned/arikawa/v3/utils/json/option"
 )
 
+// maxAutocompleteChoices is how many choices Discord will accept in an autocomplete response.
+const maxAutocompleteChoices = 25
+
+// faqKeys keeps the FAQ topics in memory, as autocomplete asks for them on every keypress.
+var faqKeys = storage.NewKeyCache("faq")
+
 func init() {
 	command.Register("faq", commandFaqObject)
 	command.Register("faqset", commandFaqSetObject)
@@ -29,6 +35,7 @@ func init() {
 var commandFaqObject = command.Handler{
 	Description: "Look up a FAQ topic",
 	Code:        CommandFaq,
+	Access:      command.EveryoneAccess,
 	Options: []discord.CommandOption{
 		&discord.StringOption{
 			OptionName:   "topic",
@@ -42,6 +49,7 @@ var commandFaqObject = command.Handler{
 var commandFaqSetObject = command.Handler{
 	Description: "Manage FAQ topics",
 	Code:        CommandFaqSet,
+	Access:      command.ModeratorAccess,
 	Options: []discord.CommandOption{
 		&discord.SubcommandOption{
 			OptionName:  "add",
@@ -104,7 +112,7 @@ func CommandFaqSet(state *state.State, kvs storage.KeyValueStore, event *gateway
 	case "add":
 		return command.Response{Response: SubCommandFaqAdd(kvs, event.GuildID, event.SenderID(), cmd.Options[0].Options), Callback: nil}
 	case "remove":
-		return command.Response{Response: SubCommandFaqRemove(kvs, event.GuildID, cmd.Options[0].Options), Callback: nil}
+		return command.Response{Response: SubCommandFaqRemove(state, kvs, event.GuildID, event.SenderID(), cmd.Options[0].Options), Callback: nil}
 	default:
 		return command.Response{Response: response.Ephemeral("Unknown subcommand! Clearly *someone* dropped the ball!"), Callback: nil}
 	}
@@ -141,7 +149,7 @@ func SubCommandFaqAdd(kvs storage.KeyValueStore, guildID discord.GuildID, userID
 }
 
 // SubCommandFaqRemove processes a command to remove a FAQ item.
-func SubCommandFaqRemove(kvs storage.KeyValueStore, guildID discord.GuildID, options []discord.CommandInteractionOption) api.InteractionResponse {
+func SubCommandFaqRemove(state *state.State, kvs storage.KeyValueStore, guildID discord.GuildID, userID discord.UserID, options []discord.CommandInteractionOption) api.InteractionResponse {
 	if options == nil || len(options) != 1 {
 		log.Printf("[%s] /faqset remove command structure is somehow nil or not one element. Wat.\n", guildID)
 		return response.Ephemeral("Invalid command structure.")
@@ -156,11 +164,18 @@ func SubCommandFaqRemove(kvs storage.KeyValueStore, guildID discord.GuildID, opt
 	if !exists {
 		return response.Ephemeral(fmt.Sprintf("Sorry, I've never heard of %s", topic))
 	}
-	err = kvs.Delete(guildID, "faq", topic)
+	entry := storage.AuditEntry{UserID: userID, Feature: "faq", Action: "remove", Target: topic, Old: value}
+	err = kvs.Batch(func(tx storage.Tx) error {
+		if err := tx.Delete(guildID, "faq", topic); err != nil {
+			return err
+		}
+		return storage.RecordAudit(tx, guildID, &entry)
+	})
 	if err != nil {
 		log.Printf("[%s] /faqset remove failed to Delete the topic %s: %s", guildID, topic, err)
 		return response.Ephemeral("An error occured, and has been logged.")
 	}
+	mirrorAudit(state, kvs, guildID, entry)
 	return response.MessageNoMention(fmt.Sprintf("Forgot %s: %s", topic, value))
 }
 
@@ -186,11 +201,25 @@ func SubCommandFaqList(kvs storage.KeyValueStore, guildID discord.GuildID) api.I
 func FAQAddModalHandler(state *state.State, kvs storage.KeyValueStore, event *gateway.InteractionCreateEvent, interaction *discord.ModalInteraction) command.Response {
 	data := modal.DecodeModalResponse(interaction.Components)
 	for key, value := range data {
-		err := kvs.Set(event.GuildID, "faq", key, value)
+		entry := storage.AuditEntry{UserID: event.SenderID(), Feature: "faq", Action: "add", Target: key, New: value}
+		err := kvs.Batch(func(tx storage.Tx) error {
+			exist, err := tx.Get(event.GuildID, "faq", key, &entry.Old)
+			if err != nil {
+				return err
+			}
+			if exist {
+				entry.Action = "update"
+			}
+			if err := tx.Set(event.GuildID, "faq", key, value); err != nil {
+				return err
+			}
+			return storage.RecordAudit(tx, event.GuildID, &entry)
+		})
 		if err != nil {
 			log.Printf("[%s] Error storing FAQ item %q: %s", event.GuildID, key, err)
 			return command.Response{Response: response.Ephemeral("There was an error saving that, but it has been logged!"), Callback: nil}
 		}
+		mirrorAudit(state, kvs, event.GuildID, entry)
 		// Early return because we only expect one, but ranging over the one is the simplest code. *shrug*
 		return command.Response{Response: response.MessageNoMention(fmt.Sprintf("Neat! I learned all about %q", key)), Callback: nil}
 	}
@@ -209,16 +238,17 @@ func FaqAutocomplete(state *state.State, kvs storage.KeyValueStore, event *gatew
 	typed := strings.ToLower(value.String())
 	typed = strings.ReplaceAll(typed, "\"", "") // Because the value is quoted, for some damn reason.
 
-	keys, err := kvs.Keys(event.GuildID, "faq")
+	keys, err := faqKeys.Keys(kvs, event.GuildID)
 	if err != nil {
 		log.Printf("[%s] Error looking up FAQ keys: %s", event.GuildID, err)
-		return choices // Still empty at this point.
+		return api.AutocompleteStringChoices{}
 	}
-
-	for _, key := range keys {
-		if strings.HasPrefix(key, typed) {
-			choices = append(choices, discord.StringChoice{Name: utility.UcFirst(key), Value: key})
+	start := sort.SearchStrings(keys, typed)
+	for _, key := range keys[start:] {
+		if !strings.HasPrefix(key, typed) || len(choices) >= maxAutocompleteChoices {
+			break
 		}
+		choices = append(choices, discord.StringChoice{Name: utility.UcFirst(key), Value: key})
 	}
 
 	return choices
@@ -0,0 +1,39 @@
+package guildcreate
+
+import (
+	"komainu/interactions/crash"
+	"komainu/storage"
+
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/state"
+)
+
+type Handler struct {
+	Code HandlerFunction
+}
+
+type HandlerFunction func(
+	state *state.State,
+	kvs storage.KeyValueStore,
+	event *gateway.GuildCreateEvent,
+)
+
+var guildcreatehandlers = []Handler{}
+
+// Register makes the Code spin when a guild shows up, be it on connecting, after an outage, or on being added to it
+func Register(handler Handler) {
+	guildcreatehandlers = append(guildcreatehandlers, handler)
+}
+
+// Add the guild create handler to the given state
+// This is mostly just pointless abstraction for uniformity across events.
+func AddHandler(state *state.State, kvs storage.KeyValueStore) {
+	state.AddHandler(func(event *gateway.GuildCreateEvent) {
+		for _, handler := range guildcreatehandlers {
+			func() {
+				defer crash.Recover(state, event.ID, "guild create event")
+				handler.Code(state, kvs, event)
+			}()
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package interactions
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"komainu/interactions/command"
+	"komainu/interactions/middleware"
+	"komainu/interactions/response"
+	"komainu/storage"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/state"
+)
+
+func init() {
+	command.Register("guilddata", commandGuildDataObject)
+}
+
+// Guild exports are downloaded from Discord with a timeout, and no larger than this.
+const maxImportSize = 25 * 1024 * 1024
+
+var importClient = &http.Client{Timeout: 30 * time.Second}
+
+var commandGuildDataObject = command.Handler{
+	Description: "Export or import everything stored for this guild (bot owner only)",
+	Code:        CommandGuildData,
+	Access:      command.AdminAccess,
+	Deferral:    command.DeferredEphemeral,
+	Middlewares: []middleware.Middleware{command.OwnerOnly},
+	Options: []discord.CommandOption{
+		&discord.SubcommandOption{
+			OptionName:  "export",
+			Description: "Get everything stored for this guild as a JSON file",
+			Options:     []discord.CommandOptionValue{},
+		},
+		&discord.SubcommandOption{
+			OptionName:  "import",
+			Description: "Restore this guild from a JSON export",
+			Options: []discord.CommandOptionValue{
+				&discord.AttachmentOption{
+					OptionName:  "file",
+					Description: "A file made by /guilddata export",
+					Required:    true,
+				},
+			},
+		},
+	},
+}
+
+// CommandGuildData processes the owner-only command to export and import guild data.
+func CommandGuildData(state *state.State, kvs storage.KeyValueStore, event *gateway.InteractionCreateEvent, cmd *discord.CommandInteraction) command.Response {
+	if cmd.Options == nil || len(cmd.Options) != 1 {
+		log.Printf("[%s] /guilddata command structure is somehow nil or not a single element. Wat.\n", event.GuildID)
+		return command.Response{Response: response.Ephemeral("I'm sorry, what? Something very weird happened.")}
+	}
+	switch cmd.Options[0].Name {
+	case "export":
+		return command.Response{Response: SubCommandGuildDataExport(kvs, event.GuildID)}
+	case "import":
+		return command.Response{Response: SubCommandGuildDataImport(kvs, event.GuildID, cmd)}
+	default:
+		return command.Response{Response: response.Ephemeral("Unknown subcommand! Clearly *someone* dropped the ball!")}
+	}
+}
+
+// SubCommandGuildDataExport processes a subcommand to export the guild data as a JSON file.
+func SubCommandGuildDataExport(kvs storage.KeyValueStore, guildID discord.GuildID) api.InteractionResponse {
+	export, err := storage.ExportGuild(kvs, guildID)
+	if err != nil {
+		log.Printf("[%s] /guilddata export failed: %s", guildID, err)
+		return response.Ephemeral("An error occured, and has been logged.")
+	}
+	var bt bytes.Buffer
+	if err := storage.WriteGuildExport(&bt, export); err != nil {
+		log.Printf("[%s] /guilddata export failed to write JSON: %s", guildID, err)
+		return response.Ephemeral("An error occured, and has been logged.")
+	}
+	log.Printf("[%s] Guild data exported", guildID)
+	return response.EphemeralAttachFile(
+		fmt.Sprintf("Here is everything I have stored for this guild, across %d collections.", len(export.Collections)),
+		fmt.Sprintf("guild_export_%s_%s.json", guildID, time.Now().Format("2006-01-02")),
+		&bt,
+	)
+}
+
+// SubCommandGuildDataImport processes a subcommand to restore the guild data from an attached JSON file.
+func SubCommandGuildDataImport(kvs storage.KeyValueStore, guildID discord.GuildID, cmd *discord.CommandInteraction) api.InteractionResponse {
+	options := cmd.Options[0].Options
+	if options == nil || len(options) != 1 {
+		log.Printf("[%s] /guilddata import command structure is somehow not exactly one element. Wat.\n", guildID)
+		return response.Ephemeral("Invalid command structure.")
+	}
+	snowflake, err := options[0].SnowflakeValue()
+	if err != nil {
+		log.Printf("[%s] /guilddata import failed to get attachment snowflake: %s", guildID, err)
+		return response.Ephemeral("An error occured, and has been logged.")
+	}
+	attachment, ok := cmd.Resolved.Attachments[discord.AttachmentID(snowflake)]
+	if !ok {
+		log.Printf("[%s] /guilddata import attachment %s was not resolved", guildID, snowflake)
+		return response.Ephemeral("I couldn't find the file you attached?!")
+	}
+
+	if attachment.Size > maxImportSize {
+		return response.Ephemeral(fmt.Sprintf("That file is too big. Guild exports can be up to %d MiB.", maxImportSize/1024/1024))
+	}
+	resp, err := importClient.Get(attachment.URL)
+	if err != nil {
+		log.Printf("[%s] /guilddata import failed to download the attachment: %s", guildID, err)
+		return response.Ephemeral("I couldn't download that file. The error has been logged.")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[%s] /guilddata import failed to download the attachment: %s", guildID, resp.Status)
+		return response.Ephemeral("I couldn't download that file. The error has been logged.")
+	}
+
+	export, err := storage.ReadGuildExport(io.LimitReader(resp.Body, maxImportSize))
+	if err != nil {
+		log.Printf("[%s] /guilddata import failed to read the export: %s", guildID, err)
+		return response.Ephemeral("That doesn't look like a guild export to me.")
+	}
+	count, err := storage.ImportGuild(kvs, guildID, export)
+	if err != nil {
+		log.Printf("[%s] /guilddata import failed: %s", guildID, err)
+		return response.Ephemeral("The import failed, so nothing was changed. The error has been logged.")
+	}
+	log.Printf("[%s] Guild data imported from %s export, %d keys", guildID, export.GuildID, count)
+	return response.Ephemeral(fmt.Sprintf("Imported %d keys.", count))
+}
@@ -0,0 +1,39 @@
+package guilddelete
+
+import (
+	"komainu/interactions/crash"
+	"komainu/storage"
+
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/state"
+)
+
+type Handler struct {
+	Code HandlerFunction
+}
+
+type HandlerFunction func(
+	state *state.State,
+	kvs storage.KeyValueStore,
+	event *gateway.GuildDeleteEvent,
+)
+
+var guilddeletehandlers = []Handler{}
+
+// Register makes the Code spin when the bot is removed from a guild, or the guild becomes unavailable
+func Register(handler Handler) {
+	guilddeletehandlers = append(guilddeletehandlers, handler)
+}
+
+// Add the guild delete handler to the given state
+// This is mostly just pointless abstraction for uniformity across events.
+func AddHandler(state *state.State, kvs storage.KeyValueStore) {
+	state.AddHandler(func(event *gateway.GuildDeleteEvent) {
+		for _, handler := range guilddeletehandlers {
+			func() {
+				defer crash.Recover(state, event.ID, "guild delete event")
+				handler.Code(state, kvs, event)
+			}()
+		}
+	})
+}
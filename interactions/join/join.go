@@ -1,6 +1,7 @@
 package join
 
 import (
+	"komainu/interactions/crash"
 	"komainu/storage"
 
 	"github.com/diamondburned/arikawa/v3/gateway"
@@ -29,7 +30,10 @@ func Register(handler Handler) {
 func AddHandler(state *state.State, kvs storage.KeyValueStore) {
 	state.AddHandler(func(event *gateway.GuildMemberAddEvent) {
 		for _, handler := range joinhandlers {
-			handler.Code(state, kvs, event)
+			func() {
+				defer crash.Recover(state, event.GuildID, "member join event")
+				handler.Code(state, kvs, event)
+			}()
 		}
 	})
 }
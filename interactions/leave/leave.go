@@ -1,6 +1,7 @@
 package leave
 
 import (
+	"komainu/interactions/crash"
 	"komainu/storage"
 
 	"github.com/diamondburned/arikawa/v3/gateway"
@@ -29,7 +30,10 @@ func Register(handler Handler) {
 func AddHandler(state *state.State, kvs storage.KeyValueStore) {
 	state.AddHandler(func(event *gateway.GuildMemberRemoveEvent) {
 		for _, handler := range leavehandlers {
-			handler.Code(state, kvs, event)
+			func() {
+				defer crash.Recover(state, event.GuildID, "member leave event")
+				handler.Code(state, kvs, event)
+			}()
 		}
 	})
 }
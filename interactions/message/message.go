@@ -1,6 +1,7 @@
 package message
 
 import (
+	"komainu/interactions/crash"
 	"komainu/storage"
 	"regexp"
 
@@ -31,7 +32,10 @@ func AddHandler(state *state.State, kvs storage.KeyValueStore) {
 	state.AddHandler(func(event *gateway.MessageCreateEvent) {
 		for _, handler := range messagehandlers {
 			if handler.Match == nil || handler.Match.MatchString(event.Content) {
-				handler.Code(state, kvs, event)
+				func() {
+					defer crash.Recover(state, event.GuildID, "message create event")
+					handler.Code(state, kvs, event)
+				}()
 			}
 		}
 	})
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"komainu/utility"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// slowInteraction is how long handling an interaction can take before it's logged. Discord gives up waiting after three seconds.
+const slowInteraction = 2 * time.Second
+
+// Metric sums up how handling one command, modal or component has gone since the bot started.
+type Metric struct {
+	Name    string // As the Context describes it, like "/faq command".
+	Count   int
+	Errors  int
+	Total   time.Duration
+	Slowest time.Duration
+}
+
+// Average is how long handling took on average.
+func (metric Metric) Average() time.Duration {
+	if metric.Count == 0 {
+		return 0
+	}
+	return metric.Total / time.Duration(metric.Count)
+}
+
+var metrics = map[string]*Metric{}
+var metricsLock sync.Mutex
+
+// Timing measures how long handling takes, keeping count for Metrics and logging anything slow.
+// It goes outside Recover, so handlers that panic are counted too, as errors.
+func Timing(ctx *Context, next Handle) error {
+	start := time.Now()
+	err := next(ctx)
+	took := time.Since(start)
+
+	name := ctx.String()
+	metricsLock.Lock()
+	metric, ok := metrics[name]
+	if !ok {
+		metric = &Metric{Name: name}
+		metrics[name] = metric
+	}
+	metric.Count++
+	metric.Total += took
+	if err != nil {
+		metric.Errors++
+	}
+	if took > metric.Slowest {
+		metric.Slowest = took
+	}
+	metricsLock.Unlock()
+
+	if took > slowInteraction {
+		log.Printf("[%s] Handling %s took %s", ctx.Event.GuildID, name, took)
+	} else {
+		utility.Debugf("[%s] Handling %s took %s", ctx.Event.GuildID, name, took)
+	}
+	return err
+}
+
+// Metrics returns what Timing has measured so far, sorted by name.
+func Metrics() []Metric {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	all := make([]Metric, 0, len(metrics))
+	for _, metric := range metrics {
+		all = append(all, *metric)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
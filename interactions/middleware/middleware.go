@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"fmt"
+	"komainu/interactions/crash"
+	"komainu/interactions/response"
+	"komainu/storage"
+	"log"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/state"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+// Kind tells what sort of interaction is being handled.
+type Kind string
+
+const (
+	CommandKind      Kind = "command"
+	AutocompleteKind Kind = "autocomplete"
+	ModalKind        Kind = "modal"
+	ComponentKind    Kind = "component"
+)
+
+// Context is what the middlewares get to know about the interaction being handled.
+type Context struct {
+	State *state.State
+	KVS   storage.KeyValueStore
+	Event *gateway.InteractionCreateEvent
+	Kind  Kind
+	Name  string // The command, modal or component target the interaction is for.
+}
+
+// String describes the interaction for the log, like "/faq command" or "auditlog component".
+func (ctx *Context) String() string {
+	if ctx.Kind == CommandKind || ctx.Kind == AutocompleteKind {
+		return fmt.Sprintf("/%s %s", ctx.Name, ctx.Kind)
+	}
+	return fmt.Sprintf("%s %s", ctx.Name, ctx.Kind)
+}
+
+// Refuse responds to the interaction without handling it, telling the user why when the kind of interaction allows it.
+// Autocompletion can only respond with choices, so it gets none.
+func (ctx *Context) Refuse(message string) error {
+	resp := response.Ephemeral(message)
+	if ctx.Kind == AutocompleteKind {
+		resp = api.InteractionResponse{Type: api.AutocompleteResult, Data: &api.InteractionResponseData{}}
+	}
+	if err := ctx.State.RespondInteraction(ctx.Event.ID, ctx.Event.Token, resp); err != nil {
+		return fmt.Errorf("could not refuse the interaction: %w", err)
+	}
+	return nil
+}
+
+// Handle handles an interaction, returning what went wrong for the middlewares to deal with.
+type Handle func(ctx *Context) error
+
+// Middleware does its bit around handling an interaction. It calls next to carry on handling it, or doesn't to stop it there.
+type Middleware func(ctx *Context, next Handle) error
+
+// Run handles the interaction through each set of middlewares in turn, outermost first, and then the handle itself.
+// Dispatchers pass their own middlewares followed by those of the handler.
+func Run(ctx *Context, handle Handle, middlewares ...[]Middleware) error {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		for j := len(middlewares[i]) - 1; j >= 0; j-- {
+			middleware, next := middlewares[i][j], handle
+			handle = func(ctx *Context) error { return middleware(ctx, next) }
+		}
+	}
+	return handle(ctx)
+}
+
+// ReportErrors logs whatever went wrong handling the interaction. It goes first, so it sees everything the others return.
+func ReportErrors(ctx *Context, next Handle) error {
+	err := next(ctx)
+	if err != nil {
+		log.Printf("[%s] Failed handling %s from <@%s>: %s", ctx.Event.GuildID, ctx, ctx.Event.SenderID(), err)
+	}
+	return err
+}
+
+// Recover turns a panic while handling the interaction into an error, so one broken handler doesn't take the bot down with it.
+// The panic is reported with an error ID, see crash.Report, and the user is told that ID so they can pass it on.
+func Recover(ctx *Context, next Handle) (err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		errorID := crash.Report(ctx.State, ctx.Event.GuildID, ctx.String(), recovered)
+		err = fmt.Errorf("panic, error %s: %v", errorID, recovered)
+		message := fmt.Sprintf("Sorry, something broke on my end. If it keeps happening, tell the bot owner about error `%s`.", errorID)
+		if ctx.Refuse(message) == nil || ctx.Kind == AutocompleteKind {
+			return
+		}
+		// The interaction was already responded to or acknowledged, so the message has to follow up instead.
+		_, followUpErr := ctx.State.CreateInteractionFollowup(ctx.Event.AppID, ctx.Event.Token, api.InteractionResponseData{
+			Content: option.NewNullableString(message),
+			Flags:   api.EphemeralResponse,
+		})
+		if followUpErr != nil {
+			err = fmt.Errorf("%w, and could not tell the user: %s", err, followUpErr)
+		}
+	}()
+	return next(ctx)
+}
+
+// GuildOnly refuses interactions from outside a guild, as everything the bot does belongs to one.
+func GuildOnly(ctx *Context, next Handle) error {
+	if ctx.Event.GuildID == discord.NullGuildID || ctx.Event.Member == nil {
+		return ctx.Refuse("I'm sorry, I do not respond to commands in private.")
+	}
+	return next(ctx)
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"fmt"
+	"komainu/storage"
+	"komainu/utility"
+	"log"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// Guilds can pick the limits every command shares for themselves. Commands with limits of their own in the configuration keep those.
+var (
+	throttleUser    = storage.RateSetting("throttle.user", "How many commands each user can use in how long, like 5/10s")
+	throttleChannel = storage.RateSetting("throttle.channel", "How many commands can be used in each channel in how long, like 10/10s")
+)
+
+// throttleKey picks a bucket in the token bins.
+type throttleKey struct {
+	Command string // Blank for the limits every command shares.
+	Guild   discord.GuildID
+	ID      discord.Snowflake // The user or channel.
+}
+
+// The Token Bins. Their limits come from the configuration and the guild settings, see throttleLimits.
+var userTokenBin = &utility.TokenBin[throttleKey]{}
+var channelTokenBin = &utility.TokenBin[throttleKey]{}
+
+// throttleLimits picks the limits for the interaction, which may have been reconfigured since the last one.
+// A command with limits of its own in the configuration uses those, and counts them under its name.
+// Every other command uses the limits of the guild, or those of the configuration when the guild hasn't picked any.
+func throttleLimits(ctx *Context) (user utility.Limit, channel utility.Limit, command string) {
+	limits, own := storage.CurrentConfiguration().Throttle.CommandLimits(ctx.Name)
+	user, channel = limits.User(), limits.Channel()
+	if own {
+		return user, channel, ctx.Name
+	}
+	if guildUser, isSet, err := throttleUser.Get(ctx.KVS, ctx.Event.GuildID); err != nil {
+		log.Printf("[%s] Failed to look up the user throttle, so using the default: %s", ctx.Event.GuildID, err)
+	} else if isSet {
+		user = guildUser
+	}
+	if guildChannel, isSet, err := throttleChannel.Get(ctx.KVS, ctx.Event.GuildID); err != nil {
+		log.Printf("[%s] Failed to look up the channel throttle, so using the default: %s", ctx.Event.GuildID, err)
+	} else if isSet {
+		channel = guildChannel
+	}
+	return user, channel, ""
+}
+
+// Throttle refuses interactions from users, and in channels, that have been busy for a while. Needs GuildOnly before it.
+func Throttle(ctx *Context, next Handle) error {
+	user, channel, command := throttleLimits(ctx)
+	if ok, wait := userTokenBin.Allocate(throttleKey{command, ctx.Event.GuildID, discord.Snowflake(ctx.Event.SenderID())}, user); !ok {
+		return ctx.Refuse(fmt.Sprintf("You are using too many commands too quickly. Calm down, and try again in %s.", RoundWait(wait)))
+	}
+	if ok, wait := channelTokenBin.Allocate(throttleKey{command, ctx.Event.GuildID, discord.Snowflake(ctx.Event.ChannelID)}, channel); !ok {
+		return ctx.Refuse(fmt.Sprintf("Too many commands being processed in this channel right now. Please wait %s.", RoundWait(wait)))
+	}
+	return next(ctx)
+}
+
+// RoundWait rounds how long someone has to wait up to the next second, so they aren't told to wait 0s.
+func RoundWait(wait time.Duration) time.Duration {
+	return (wait + time.Second - 1).Truncate(time.Second)
+}
@@ -1,7 +1,9 @@
 package modal
 
 import (
+	"fmt"
 	"komainu/interactions/command"
+	"komainu/interactions/middleware"
 	"komainu/interactions/response"
 	"komainu/storage"
 	"log"
@@ -17,7 +19,9 @@ import (
 )
 
 type Handler struct {
-	Code HandlerFunction
+	Code        HandlerFunction
+	Deferral    command.Deferral
+	Middlewares []middleware.Middleware // Run after the ones every modal goes through.
 }
 
 type HandlerFunction func(
@@ -41,6 +45,14 @@ var modals = map[string]Handler{}
 
 var modalSecrets = map[string]Secret{}
 
+// middlewares are what every modal goes through before its own, outermost first.
+var middlewares = []middleware.Middleware{
+	middleware.ReportErrors,
+	middleware.Timing,
+	middleware.Recover,
+	middleware.GuildOnly,
+}
+
 func init() {
 	go startRemovingStaleSecrets()
 }
@@ -55,20 +67,10 @@ func AddHandler(state *state.State, kvs storage.KeyValueStore) {
 					log.Printf("[%s] Modal form submission from WRONG USER: %s, but expected %s", e.GuildID, e.SenderID(), secret.User)
 				}
 				if val, ok := modals[secret.Handler]; ok {
-					response := val.Code(state, kvs, e, interaction)
-					if err := state.RespondInteraction(e.ID, e.Token, response.Response); err != nil {
-						log.Printf("[%s] Failed to send modal interaction response: %s", e.GuildID, err)
-					}
-					if response.Callback != nil {
-						message, err := state.InteractionResponse(e.AppID, e.Token)
-						if err != nil {
-							log.Printf("Error %s getting message reference for %s modal callback\n", err, id)
-							return
-						}
-						if message != nil && message.ID != discord.NullMessageID {
-							response.Callback(message)
-						}
-					}
+					ctx := &middleware.Context{State: state, KVS: kvs, Event: e, Kind: middleware.ModalKind, Name: secret.Handler}
+					middleware.Run(ctx, func(ctx *middleware.Context) error {
+						return val.handle(ctx, interaction)
+					}, middlewares, val.Middlewares)
 				} else {
 					log.Printf("[%s] has UNKNOWN modal interaction %#v", e.GuildID, secret)
 				}
@@ -84,6 +86,19 @@ func AddHandler(state *state.State, kvs storage.KeyValueStore) {
 	})
 }
 
+// handle runs the handler itself, once the middlewares are done with it.
+func (val Handler) handle(ctx *middleware.Context, interaction *discord.ModalInteraction) error {
+	ack, err := command.Acknowledge(ctx.State, ctx.Event, val.Deferral)
+	if err != nil {
+		log.Printf("[%s] Failed to acknowledge %s modal, so responding without deferring: %s", ctx.Event.GuildID, ctx.Name, err)
+	}
+	response := val.Code(ctx.State, ctx.KVS, ctx.Event, interaction)
+	if err := command.Deliver(ctx.State, ctx.Event, ack, response); err != nil {
+		return fmt.Errorf("could not send response: %w", err)
+	}
+	return nil
+}
+
 func startRemovingStaleSecrets() {
 	ticker := time.NewTicker(1 * time.Minute)
 	for {
@@ -0,0 +1,42 @@
+package interactions
+
+import (
+	"komainu/interactions/guildcreate"
+	"komainu/interactions/guilddelete"
+	"komainu/storage"
+	"log"
+
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/state"
+)
+
+func init() {
+	guilddelete.Register(guilddelete.Handler{Code: schedulePurge})
+	guildcreate.Register(guildcreate.Handler{Code: cancelPurge})
+}
+
+// schedulePurge marks the guild's data for deletion when the bot is removed from it.
+func schedulePurge(state *state.State, kvs storage.KeyValueStore, event *gateway.GuildDeleteEvent) {
+	if event.Unavailable {
+		log.Printf("[%s] Guild became unavailable, which is an outage rather than a goodbye. Keeping the data.", event.ID)
+		return
+	}
+	grace := storage.CurrentConfiguration().PurgeAfter.Value()
+	if err := storage.SchedulePurge(kvs, event.ID, grace); err != nil {
+		log.Printf("[%s] Removed from guild, but failed to schedule a purge: %s", event.ID, err)
+		return
+	}
+	log.Printf("[%s] Removed from guild, so all guild data will be purged in %s unless I'm added back", event.ID, grace)
+}
+
+// cancelPurge keeps the guild's data after all, if the bot is added back before the purge happened.
+func cancelPurge(state *state.State, kvs storage.KeyValueStore, event *gateway.GuildCreateEvent) {
+	cancelled, err := storage.CancelPurge(kvs, event.ID)
+	if err != nil {
+		log.Printf("[%s] Failed to cancel a pending purge: %s", event.ID, err)
+		return
+	}
+	if cancelled {
+		log.Printf("[%s] Added back to guild, so the pending purge of guild data was cancelled", event.ID)
+	}
+}
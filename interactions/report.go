@@ -16,8 +16,9 @@ import (
 func init() {
 	command.Register("Report message", command.Handler{
 		//Description: "Bring message to moderator attention",
-		Type: discord.MessageCommand,
-		Code: CommandReport,
+		Type:   discord.MessageCommand,
+		Code:   CommandReport,
+		Access: command.EveryoneAccess,
 	})
 	// TODO: Write and register a handler for a modal response here.
 }
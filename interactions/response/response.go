@@ -58,3 +58,10 @@ func MessageAttachFile(message string, name string, reader io.Reader) api.Intera
 		},
 	}
 }
+
+// EphemeralAttachFile generates an ephemeral InteractionResponse from the string given, and attaches the given file.
+func EphemeralAttachFile(message string, name string, reader io.Reader) api.InteractionResponse {
+	resp := MessageAttachFile(message, name, reader)
+	resp.Data.Flags = api.EphemeralResponse
+	return resp
+}
@@ -26,9 +26,10 @@ func init() {
 		Description: "Create a role self-assignment message",
 		Options:     createRoleOptions(),
 		Code:        CommandRoleSelector,
+		Access:      command.AdminAccess,
 	})
 	modal.Register("roleselect", modal.Handler{Code: RoleSelectorModalHandler})
-	component.Register("roleselect", component.Handler{Code: ComponentRoleSelector})
+	component.Register("roleselect", component.Handler{Code: ComponentRoleSelector, Deferral: command.Deferred})
 
 	delete.Register(delete.Handler{Code: DeleteRoleButton})
 	command.Register("rolebutton", command.Handler{
@@ -39,10 +40,11 @@ func init() {
 				Description: "The role you want a button for",
 			},
 		},
-		Code: CommandRoleButton,
+		Code:   CommandRoleButton,
+		Access: command.AdminAccess,
 	})
 	modal.Register("rolebutton", modal.Handler{Code: RoleButtonModalHandler})
-	component.Register("rolebutton", component.Handler{Code: ComponentRoleButton})
+	component.Register("rolebutton", component.Handler{Code: ComponentRoleButton, Deferral: command.Deferred})
 }
 
 var roleFinder = regexp.MustCompile("<@&[0-9]+>")
@@ -245,7 +247,8 @@ func RoleSelectorModalHandler(state *state.State, kvs storage.KeyValueStore, eve
 	roleIDs := roleCleanup(roleStrings)
 	thisGuild, err := state.Guild(event.GuildID)
 	if err != nil {
-		log.Printf("[%s] WTF, I could not obtain a guild object to look up it's roles.", event.GuildID)
+		log.Printf("[%s] WTF, I could not obtain a guild object to look up it's roles: %s", event.GuildID, err)
+		return command.Response{Response: response.Ephemeral("OK, something really weird happened, but it has been logged, so maybe it'll get fixed.")}
 	}
 	guildRoles := makeRoleMap(thisGuild)
 
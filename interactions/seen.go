@@ -7,19 +7,20 @@ import (
 	"komainu/interactions/message"
 	"komainu/interactions/response"
 	"komainu/storage"
+	"komainu/utility"
 	"log"
 	"time"
 
 	"github.com/diamondburned/arikawa/v3/discord"
 	"github.com/diamondburned/arikawa/v3/gateway"
 	"github.com/diamondburned/arikawa/v3/state"
-	"github.com/diamondburned/arikawa/v3/utils/json/option"
 )
 
 func init() {
 	command.Register("seen", command.Handler{
 		Description: "Check when someone was last around",
 		Code:        CommandSeen,
+		Access:      command.EveryoneAccess,
 		Options: []discord.CommandOption{
 			&discord.UserOption{
 				OptionName:  "user",
@@ -31,11 +32,15 @@ func init() {
 	command.Register("neverseen", command.Handler{
 		Description: "Get a list of people that the bot has never seen say anything!",
 		Code:        CommandNeverSeen,
+		Access:      command.ModeratorAccess,
+		Deferral:    command.Deferred,
 		Options:     []discord.CommandOption{},
 	})
 	command.Register("inactive", command.Handler{
 		Description: "Get a list of inactive people",
 		Code:        CommandInactive,
+		Access:      command.ModeratorAccess,
+		Deferral:    command.Deferred,
 		Options: []discord.CommandOption{
 			&discord.IntegerOption{
 				OptionName:  "days",
@@ -44,27 +49,12 @@ func init() {
 			},
 		},
 	})
-	command.Register("activerole", command.Handler{
-		Description: "Set what role is granted and revoked for active/inactive users, and under what conditions.",
-		Code:        CommandActiveRole,
-		Options: []discord.CommandOption{
-			&discord.RoleOption{
-				OptionName:  "role",
-				Description: "The role to giveth and taketh away.",
-				Required:    true,
-			},
-			&discord.NumberOption{
-				OptionName:  "days",
-				Description: "How many days someone needs to be inactive to lose the role. Set to zero to disable this function.",
-				Required:    true,
-				Min:         option.NewFloat(0),
-				Max:         option.NewFloat(365),
-			},
-		},
-	})
 	command.Register("seeeveryone", command.Handler{
 		Description: "Ruin the /seen system by marking everyone here as seen right now.",
 		Code:        CommandSeeEveryone,
+		Access:      command.AdminAccess,
+		Deferral:    command.Deferred,
+		Cooldown:    command.Cooldown{Per: command.PerGuild, Duration: 10 * time.Minute, DailyCap: 10},
 		Options:     []discord.CommandOption{},
 	})
 	message.Register(message.Handler{Code: MessageSeen})
@@ -75,13 +65,10 @@ func MessageSeen(state *state.State, kvs storage.KeyValueStore, event *gateway.M
 		return // It's either a private message, or an ephemeral-response command. Doesn't count.
 	}
 
-	if err := storage.See(kvs, event.GuildID, event.Author.ID); err != nil {
-		log.Printf("[%s] Error seeing %s in %s: %s\n", event.GuildID, event.Author.ID, event.ChannelID, err)
-	} else {
-		log.Printf("[%s] <@%s> seen in <#%s>\n", event.GuildID, event.Author.ID, event.ChannelID)
-		if err := storage.MaybeGiveActiveRole(kvs, state, event.GuildID, event.Member); err != nil {
-			log.Printf("[%s] Failed to give active role to %s: %s\n", event.GuildID, event.Author.ID, err)
-		}
+	storage.SeeBuffered(event.GuildID, event.Author.ID)
+	utility.Debugf("[%s] <@%s> seen in <#%s>\n", event.GuildID, event.Author.ID, event.ChannelID)
+	if err := storage.MaybeGiveActiveRole(kvs, state, event.GuildID, event.Member); err != nil {
+		log.Printf("[%s] Failed to give active role to %s: %s\n", event.GuildID, event.Author.ID, err)
 	}
 }
 
@@ -232,50 +219,6 @@ func CommandNeverSeen(state *state.State, kvs storage.KeyValueStore, event *gate
 	}
 }
 
-// CommandActiveRole processes a command to set an automatic "active" role and revoke it after a certain amount of days.
-func CommandActiveRole(state *state.State, kvs storage.KeyValueStore, event *gateway.InteractionCreateEvent, cmd *discord.CommandInteraction) command.Response {
-	if cmd.Options == nil || len(cmd.Options) != 2 {
-		log.Printf("[%s] /activerole has a weird number of arguments\n", event.GuildID)
-		return command.Response{Response: response.Ephemeral("Wait, what? Something odd happened, and was logged."), Callback: nil}
-	}
-
-	// Processing these in reverse order because of the Special Meaning of days == 0
-	days, err := cmd.Options[1].FloatValue()
-	if err != nil {
-		log.Printf("[%s] Error encountered trying to turn days argument into an actual number in /activerole: %s\n", event.GuildID, err)
-		return command.Response{Response: response.Ephemeral("That's very odd. I've logged that it didn't go according to plan."), Callback: nil}
-	}
-
-	if days == 0 {
-		if err := kvs.Delete(event.GuildID, "activerole", "days"); err != nil {
-			log.Printf("[%s] Tried to disable activerole, but %s", event.GuildID, err)
-		}
-		if err := kvs.Delete(event.GuildID, "activerole", "role"); err != nil {
-			log.Printf("[%s] Tried to disable activerole, however %s", event.GuildID, err)
-		}
-		return command.Response{Response: response.Message("So noted. Feature disabled."), Callback: nil}
-	}
-
-	if err := kvs.Set(event.GuildID, "activerole", "days", days); err != nil {
-		log.Printf("[%s] Error storing the days for /activerole: %s\n", event.GuildID, err)
-		return command.Response{Response: response.Ephemeral("There is something strange in this neighbourhood. I've logged it for the Bug Busters to investigate later."), Callback: nil}
-	}
-
-	snowflake, err := cmd.Options[0].SnowflakeValue()
-	if err != nil {
-		log.Printf("[%s] Error encountered trying to turn role argument into an actual snowflake in /activerole: %s\n", event.GuildID, err)
-		return command.Response{Response: response.Ephemeral("That's very odd. I've logged that it didn't go as planned."), Callback: nil}
-	}
-	roleID := discord.RoleID(snowflake)
-	if err := kvs.Set(event.GuildID, "activerole", "role", roleID); err != nil {
-		log.Printf("[%s] Error storing the role for /activerole: %s\n", event.GuildID, err)
-		return command.Response{Response: response.Ephemeral("There is something strange in this neighbourhood. I've logged it for the Bug Busters to look at later."), Callback: nil}
-	}
-
-	return command.Response{Response: response.MessageNoMention(fmt.Sprintf("Okay, will revoke <@&%d> after %.01f days, and grant it to anyone that says anything.", roleID, days)), Callback: nil}
-
-}
-
 // CommandSeeEveryone processes a command to mark eeeeveryone in the guild as "seen" right now.
 func CommandSeeEveryone(state *state.State, kvs storage.KeyValueStore, event *gateway.InteractionCreateEvent, cmd *discord.CommandInteraction) command.Response {
 	members, err := state.Session.Members(event.GuildID, 0)
@@ -283,12 +226,17 @@ func CommandSeeEveryone(state *state.State, kvs storage.KeyValueStore, event *ga
 		log.Printf("[%s] Failed to get member list for /SeeEveryone: %s", event.GuildID, err)
 		return command.Response{Response: response.Ephemeral("An error occured, and has been logged."), Callback: nil}
 	}
-	for _, member := range members {
-		err := storage.See(kvs, event.GuildID, member.User.ID)
-		if err != nil {
-			log.Printf("[%s] Failed to See member during seeing spree: %s", event.GuildID, err)
-			return command.Response{Response: response.Message("Okay, something weird happened partway through that. It was logged."), Callback: nil}
+	err = kvs.Batch(func(tx storage.Tx) error {
+		for _, member := range members {
+			if err := storage.See(tx, event.GuildID, member.User.ID); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[%s] Failed to See member during seeing spree: %s", event.GuildID, err)
+		return command.Response{Response: response.Message("Okay, something weird happened during that, so nobody was marked. It was logged."), Callback: nil}
 	}
 	return command.Response{Response: response.Message("Eeeeeveryone was marked as being seen just now."), Callback: nil}
 }
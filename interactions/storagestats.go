@@ -0,0 +1,91 @@
+package interactions
+
+import (
+	"errors"
+	"fmt"
+	"komainu/interactions/command"
+	"komainu/interactions/middleware"
+	"komainu/interactions/response"
+	"komainu/storage"
+	"log"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/state"
+)
+
+// maxInlineStats is how long the statistics table can get before it's sent as a file instead.
+const maxInlineStats = 1900
+
+func init() {
+	command.Register("storage", commandStorageObject)
+}
+
+var commandStorageObject = command.Handler{
+	Description: "See what is using the space in the database, or compact it (bot owner only)",
+	Code:        CommandStorage,
+	Access:      command.AdminAccess,
+	Deferral:    command.DeferredEphemeral,
+	Middlewares: []middleware.Middleware{command.OwnerOnly},
+	Options: []discord.CommandOption{
+		&discord.SubcommandOption{
+			OptionName:  "stats",
+			Description: "Key counts and sizes per guild and collection",
+			Options:     []discord.CommandOptionValue{},
+		},
+		&discord.SubcommandOption{
+			OptionName:  "compact",
+			Description: "Get rid of the space left behind by deleted keys",
+			Options:     []discord.CommandOptionValue{},
+		},
+	},
+}
+
+// CommandStorage processes the owner-only command for storage statistics and compaction.
+func CommandStorage(state *state.State, kvs storage.KeyValueStore, event *gateway.InteractionCreateEvent, cmd *discord.CommandInteraction) command.Response {
+	if cmd.Options == nil || len(cmd.Options) != 1 {
+		log.Printf("[%s] /storage command structure is somehow nil or not a single element. Wat.\n", event.GuildID)
+		return command.Response{Response: response.Ephemeral("I'm sorry, what? Something very weird happened.")}
+	}
+	switch cmd.Options[0].Name {
+	case "stats":
+		return command.Response{Response: SubCommandStorageStats(kvs, event.GuildID)}
+	case "compact":
+		return command.Response{Response: SubCommandStorageCompact(kvs, event.GuildID)}
+	default:
+		return command.Response{Response: response.Ephemeral("Unknown subcommand! Clearly *someone* dropped the ball!")}
+	}
+}
+
+// SubCommandStorageStats processes a subcommand to show what is using the space in the database.
+func SubCommandStorageStats(kvs storage.KeyValueStore, guildID discord.GuildID) api.InteractionResponse {
+	stats, err := storage.Stats(kvs)
+	if errors.Is(err, storage.ErrStatsUnsupported) {
+		return response.Ephemeral("The storage I'm running on has no statistics to give.")
+	}
+	if err != nil {
+		log.Printf("[%s] /storage stats failed: %s", guildID, err)
+		return response.Ephemeral("An error occured, and has been logged.")
+	}
+	table := stats.String()
+	if len(table) > maxInlineStats {
+		return response.EphemeralAttachFile("That's a lot of statistics, so here they are as a file.", "storage_stats.txt", strings.NewReader(table))
+	}
+	return response.Ephemeral("```\n" + table + "```")
+}
+
+// SubCommandStorageCompact processes a subcommand to compact the database.
+func SubCommandStorageCompact(kvs storage.KeyValueStore, guildID discord.GuildID) api.InteractionResponse {
+	before, after, err := storage.Compact(kvs)
+	if errors.Is(err, storage.ErrCompactUnsupported) {
+		return response.Ephemeral("The storage I'm running on can't be compacted.")
+	}
+	if err != nil {
+		log.Printf("[%s] /storage compact failed: %s", guildID, err)
+		return response.Ephemeral("Compacting failed! The error has been logged.")
+	}
+	log.Printf("[%s] Storage compacted from %d to %d bytes", guildID, before, after)
+	return response.Ephemeral(fmt.Sprintf("Compacted the database from %d to %d bytes.", before, after))
+}
@@ -31,6 +31,7 @@ func init() {
 var commandVoteObject = command.Handler{
 	Description: "Initiate a vote",
 	Code:        CommandVote,
+	Access:      command.ModeratorAccess,
 	Options: []discord.CommandOption{
 		&discord.NumberOption{
 			OptionName:  "length",
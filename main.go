@@ -3,6 +3,7 @@ package main
 import (
 	"komainu/bot"
 	"komainu/storage"
+	"komainu/utility"
 	"log"
 	"os"
 	"os/signal"
@@ -12,9 +13,7 @@ import (
 
 func main() {
 
-	if os.Getenv("DEV_MODE") != "" {
-		log.SetFlags(log.Lshortfile | log.Ltime)
-	}
+	launchDir, _ = os.Getwd() // Remembered so command line file arguments still make sense after the Chdir.
 
 	cwdOverride := os.Getenv("CWD_OVERRIDE")
 	if cwdOverride == "" {
@@ -36,7 +35,18 @@ func main() {
 		}
 	}
 
+	storage.SchemaDryRun = os.Getenv("SCHEMA_DRY_RUN") != ""
+
+	if len(os.Args) > 1 {
+		if err := runCLI(os.Args[1:]); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
 	cfg := storage.GetConfiguration()
+	storage.SetCurrentConfiguration(cfg)
+	applyLogSettings(cfg)
 	if cfg.Logfile != "" {
 		log.Printf("Using %s for a log file\n", cfg.Logfile)
 		if logfileHandle, err := os.OpenFile(cfg.Logfile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640); err == nil {
@@ -47,11 +57,18 @@ func main() {
 		log.Println("No logfile specified: Will just output to STDOUT and hope for the best.")
 	}
 
-	kvs, err := storage.OpenKomainuBolt("data/komainubolt")
-	if err != nil {
-		log.Fatalln("Could not open KVS:", err)
-	}
+	kvs := openKVS(cfg)
 	defer kvs.Close()
+	defer func() {
+		if err := storage.FlushSeen(kvs); err != nil {
+			log.Println("Failed to flush seen timestamps on the way out:", err)
+		}
+	}()
+
+	if storage.SchemaDryRun {
+		log.Println("SCHEMA_DRY_RUN in effect: Pending schema migrations were logged, and that's all for now.")
+		return
+	}
 
 	log.Println("Preparing to connect to Discord")
 
@@ -62,11 +79,55 @@ func main() {
 
 }
 
+// applyLogSettings makes the log as chatty and detailed as the configuration says.
+func applyLogSettings(cfg storage.Configuration) {
+	if cfg.DevMode {
+		log.SetFlags(log.Lshortfile | log.Ltime)
+	} else {
+		log.SetFlags(log.LstdFlags)
+	}
+	utility.SetLogLevel(cfg.LogLevel)
+}
+
+// openKVS opens the KeyValueStore selected in the configuration.
+// Storage "memory" (or STORAGE=memory in the environment) gives a throwaway store that forgets everything on exit.
+func openKVS(cfg storage.Configuration) storage.KeyValueStore {
+	if err := storage.SetValueCodec(cfg.Codec); err != nil {
+		log.Fatalln("Could not select codec:", err)
+	}
+	var kvs storage.KeyValueStore
+	var err error
+	switch cfg.Storage {
+	case "memory":
+		log.Println("Storage is memory: Nothing will be saved!")
+		return storage.OpenMemory()
+	case "sqlite":
+		kvs, err = storage.OpenKomainuSQLite(cfg.DatabasePath())
+	default:
+		kvs, err = storage.OpenKomainuBolt(cfg.DatabasePath())
+	}
+	if err != nil {
+		log.Fatalln("Could not open KVS:", err)
+	}
+	return kvs
+}
+
 // WaitForInterrupt blocks until a SIGINT, SIGTERM or another OS interrupt is received.
-// "Pause until Ctrl+C", basically.
+// "Pause until Ctrl+C", basically. A SIGHUP reloads the configuration instead.
 func WaitForInterrupt() {
 	// Thanks to various Discord Gophers for this very simple stuff.
 	signalCh := make(chan os.Signal, 1)
-	signal.Notify(signalCh, syscall.SIGTERM, os.Interrupt)
-	<-signalCh
+	signal.Notify(signalCh, syscall.SIGTERM, os.Interrupt, syscall.SIGHUP)
+	for sig := range signalCh {
+		if sig != syscall.SIGHUP {
+			return
+		}
+		cfg, err := storage.ReloadConfiguration()
+		if err != nil {
+			log.Println("SIGHUP received, but:", err)
+			continue
+		}
+		applyLogSettings(cfg)
+		log.Println("SIGHUP received, configuration reloaded")
+	}
 }
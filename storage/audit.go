@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// auditCollection holds the audit log, keyed by the time of each entry so they are kept in order.
+const auditCollection = "audit"
+
+// maxAuditValueLength is how much of an old or new value String shows, as FAQ topics can go on for a while.
+const maxAuditValueLength = 100
+
+// AuditEntry records one change made by someone in a guild.
+type AuditEntry struct {
+	Time    int64 // Unix timestamp.
+	UserID  discord.UserID
+	Feature string // What the change was made to, like "faq" or "deletelog".
+	Action  string // What was done, like "set", "reset" or "remove".
+	Target  string // What exactly was changed, like a setting name or FAQ topic.
+	Old     string // The value before the change, blank if there wasn't one.
+	New     string // The value after the change, blank if there isn't one.
+}
+
+// AuditFilter picks what entries AuditLog returns. Leave a field blank to not filter on it.
+type AuditFilter struct {
+	Feature string
+	UserID  discord.UserID
+}
+
+// matches checks if the entry passes the filter.
+func (filter AuditFilter) matches(entry AuditEntry) bool {
+	if filter.Feature != "" && filter.Feature != entry.Feature {
+		return false
+	}
+	return !filter.UserID.IsValid() || filter.UserID == entry.UserID
+}
+
+// RecordAudit adds the entry to the audit log of the given guild, timestamped now if it isn't already.
+// Pass the Tx making the change, so the entry is only kept if the change is. Entries expire after the configured AuditRetention.
+func RecordAudit(tx Tx, guildID discord.GuildID, entry *AuditEntry) error {
+	now := time.Now()
+	if entry.Time == 0 {
+		entry.Time = now.Unix()
+	}
+	nanos := now.UnixNano()
+	for {
+		exist, err := tx.Get(guildID, auditCollection, auditKey(nanos), &AuditEntry{})
+		if err != nil {
+			return fmt.Errorf("could not check for audit entry: %w", err)
+		}
+		if !exist {
+			break
+		}
+		nanos++ // Two changes in the same nanosecond, but neither is getting lost.
+	}
+	if retention := CurrentConfiguration().AuditRetention.Value(); retention > 0 {
+		return tx.SetWithTTL(guildID, auditCollection, auditKey(nanos), entry, retention)
+	}
+	return tx.Set(guildID, auditCollection, auditKey(nanos), entry)
+}
+
+// auditKey zero pads the timestamp, so the keys sort in time order.
+func auditKey(nanos int64) string {
+	return fmt.Sprintf("%019d", nanos)
+}
+
+// AuditLog returns up to limit entries matching the filter, newest first, skipping the first offset of them.
+// It also returns how many entries matched in total, for paging.
+func AuditLog(kvs KeyValueStore, guildID discord.GuildID, filter AuditFilter, offset int, limit int) (entries []AuditEntry, total int, err error) {
+	matching := []AuditEntry{}
+	err = kvs.ForEach(guildID, auditCollection, func(key string, value ValueDecoder) error {
+		entry := AuditEntry{}
+		if err := value(&entry); err != nil {
+			return fmt.Errorf("could not decode audit entry %s: %w", key, err)
+		}
+		if filter.matches(entry) {
+			matching = append(matching, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	total = len(matching)
+	for i := total - 1 - offset; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, matching[i])
+	}
+	return entries, total, nil
+}
+
+// AuditFeatures lists the features that appear in the audit log of the given guild, sorted.
+func AuditFeatures(kvs KeyValueStore, guildID discord.GuildID) (features []string, err error) {
+	seen := map[string]bool{}
+	err = kvs.ForEach(guildID, auditCollection, func(key string, value ValueDecoder) error {
+		entry := AuditEntry{}
+		if err := value(&entry); err != nil {
+			return fmt.Errorf("could not decode audit entry %s: %w", key, err)
+		}
+		if !seen[entry.Feature] {
+			seen[entry.Feature] = true
+			features = append(features, entry.Feature)
+		}
+		return nil
+	})
+	sort.Strings(features)
+	return
+}
+
+// String formats the entry as a single line, suitable for a Discord message.
+func (entry AuditEntry) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<t:%d:f> %s %s %s `%s`", entry.Time, entry.UserID.Mention(), entry.Action, entry.Feature, entry.Target)
+	switch {
+	case entry.Old != "" && entry.New != "":
+		fmt.Fprintf(&sb, ": %s → %s", shortenAuditValue(entry.Old), shortenAuditValue(entry.New))
+	case entry.Old != "":
+		fmt.Fprintf(&sb, ", was %s", shortenAuditValue(entry.Old))
+	case entry.New != "":
+		fmt.Fprintf(&sb, ": %s", shortenAuditValue(entry.New))
+	}
+	return sb.String()
+}
+
+// shortenAuditValue cuts long values down to size, and keeps them on one line.
+func shortenAuditValue(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	if runes := []rune(value); len(runes) > maxAuditValueLength {
+		value = string(runes[:maxAuditValueLength-1]) + "…"
+	}
+	return value
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+func TestAuditLog(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		entries := []AuditEntry{
+			{UserID: 1, Feature: "faq", Action: "add", Target: "horseradish", New: "A root"},
+			{UserID: 2, Feature: "deletelog", Action: "set", Target: "deletelog.channel", New: "<#3>"},
+			{UserID: 1, Feature: "faq", Action: "remove", Target: "horseradish", Old: "A root"},
+		}
+		for i := range entries {
+			if err := kvs.Batch(func(tx Tx) error { return RecordAudit(tx, testGuild, &entries[i]) }); err != nil {
+				t.Errorf("Could not record audit entry: %v", err)
+				return
+			}
+		}
+		kvs.Batch(func(tx Tx) error {
+			RecordAudit(tx, testGuild, &AuditEntry{UserID: 9, Feature: "faq", Action: "add", Target: "rolled back"})
+			return errors.New("on purpose")
+		})
+
+		all, total, err := AuditLog(kvs, testGuild, AuditFilter{}, 0, 10)
+		if err != nil || total != 3 || len(all) != 3 {
+			t.Errorf("Expected 3 entries, Got %d of %d (%v)", len(all), total, err)
+			return
+		}
+		if all[0].Action != "remove" || all[2].Action != "add" || all[0].Time == 0 {
+			t.Errorf("Expected newest first with timestamps, Got %v", all)
+		}
+
+		page, total, _ := AuditLog(kvs, testGuild, AuditFilter{Feature: "faq", UserID: discord.UserID(1)}, 1, 1)
+		if total != 2 || len(page) != 1 || page[0].Action != "add" {
+			t.Errorf("Expected the second faq entry by user 1, Got %v of %d", page, total)
+		}
+
+		features, err := AuditFeatures(kvs, testGuild)
+		if err != nil || strings.Join(features, ",") != "deletelog,faq" {
+			t.Errorf("Expected deletelog and faq, Got %v (%v)", features, err)
+		}
+	})
+}
+
+func TestAuditEntryString(t *testing.T) {
+	entry := AuditEntry{Time: 1600000000, UserID: 1, Feature: "faq", Action: "update", Target: "horseradish", Old: "short", New: strings.Repeat("long\n", 50)}
+	shown := entry.String()
+	if strings.Contains(shown, "\n") || !strings.HasPrefix(shown, "<t:1600000000:f> <@1> update faq `horseradish`: short → long long") {
+		t.Errorf("Unexpected formatting: %q", shown)
+	}
+	if !strings.HasSuffix(shown, "…") || len([]rune(shown)) > 200 {
+		t.Errorf("Expected the new value to be shortened, Got %q", shown)
+	}
+}
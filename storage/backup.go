@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	backupPrefix = "komainubolt-"
+	backupSuffix = ".bolt"
+	// Down to the nanosecond, so backups taken in the same second don't overwrite each other. Sorts oldest first.
+	backupTimeFormat = "20060102-150405.000000000"
+)
+
+// ErrBackupUnsupported is returned when asked to back up a KeyValueStore that can't do that.
+var ErrBackupUnsupported = errors.New("this storage does not support backups")
+
+// Backupper is implemented by any KeyValueStore that can write a consistent snapshot of itself while in use.
+type Backupper interface {
+	Backup(w io.Writer) (size int64, err error)
+}
+
+// Backup writes a consistent copy of the whole database to the writer, without blocking anyone else.
+func (kb *komainuBolt) Backup(w io.Writer) (size int64, err error) {
+	kb.swap.RLock()
+	defer kb.swap.RUnlock()
+	err = kb.bolt.View(func(tx *bolt.Tx) (err error) {
+		size, err = tx.WriteTo(w)
+		return
+	})
+	return
+}
+
+// BackupNow writes a timestamped backup of the KeyValueStore into the configured directory, then removes old backups.
+// Returns the path to the new backup.
+func BackupNow(kvs KeyValueStore, cfg BackupConfiguration) (path string, err error) {
+	backupper, ok := kvs.(Backupper)
+	if !ok {
+		return "", ErrBackupUnsupported
+	}
+	if err := os.MkdirAll(cfg.Directory, 0770); err != nil {
+		return "", fmt.Errorf("could not create backup directory: %w", err)
+	}
+
+	path = filepath.Join(cfg.Directory, backupPrefix+time.Now().Format(backupTimeFormat)+backupSuffix)
+	partial := path + ".partial" // So a half-written backup is never mistaken for a good one.
+	fileHandle, err := os.OpenFile(partial, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0660)
+	if err != nil {
+		return "", fmt.Errorf("could not create backup file: %w", err)
+	}
+	size, err := backupper.Backup(fileHandle)
+	if closeErr := fileHandle.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(partial)
+		return "", fmt.Errorf("could not write backup: %w", err)
+	}
+	if err := os.Rename(partial, path); err != nil {
+		return "", fmt.Errorf("could not move backup into place: %w", err)
+	}
+	log.Printf("Backed up %d bytes to %s\n", size, path)
+
+	if err := RotateBackups(cfg); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// RotateBackups removes the oldest backups in the configured directory, so only the configured number are kept.
+func RotateBackups(cfg BackupConfiguration) error {
+	entries, err := os.ReadDir(cfg.Directory)
+	if err != nil {
+		return fmt.Errorf("could not list backups: %w", err)
+	}
+	backups := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	sort.Strings(backups) // The timestamp format sorts oldest first.
+	for len(backups) > cfg.Keep {
+		if err := os.Remove(filepath.Join(cfg.Directory, backups[0])); err != nil {
+			return fmt.Errorf("could not remove old backup: %w", err)
+		}
+		log.Printf("Removed old backup %s\n", backups[0])
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// StartBackingUp calls BackupNow at the configured interval. An interval of "0" turns it off.
+// Intended to be called as a goroutine.
+func StartBackingUp(kvs KeyValueStore) {
+	if _, ok := kvs.(Backupper); !ok {
+		log.Println("Not doing scheduled backups, as this storage does not support them.")
+		return
+	}
+	tickEvery(func(cfg Configuration) time.Duration { return cfg.Backup.Interval.Value() }, func() {
+		if _, err := BackupNow(kvs, CurrentConfiguration().Backup); err != nil {
+			log.Printf("Error encountered during scheduled backup: %s\n", err)
+		}
+	})
+}
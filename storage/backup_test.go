@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupNow(t *testing.T) {
+	kvs, err := GetKVS(filename)
+	if err != nil {
+		t.Errorf("Could not open test file: %s", err)
+	}
+	t.Cleanup(func() {
+		kvs.Close()
+		os.Remove(filename)
+	})
+	if err := kvs.Set(testGuild, col, "some arbituary key", "some arbituary string"); err != nil {
+		t.Errorf("Could not set test input value: %v", err)
+		return
+	}
+
+	cfg := BackupConfiguration{Directory: t.TempDir(), Keep: 2}
+	for _, old := range []string{"komainubolt-20200101-000000.bolt", "komainubolt-20200102-000000.bolt"} {
+		if err := os.WriteFile(filepath.Join(cfg.Directory, old), []byte("old"), 0660); err != nil {
+			t.Errorf("Could not create fake old backup: %v", err)
+			return
+		}
+	}
+
+	path, err := BackupNow(kvs, cfg)
+	if err != nil {
+		t.Errorf("Backup failed: %v", err)
+		return
+	}
+
+	entries, _ := os.ReadDir(cfg.Directory)
+	if len(entries) != 2 || entries[0].Name() != "komainubolt-20200102-000000.bolt" {
+		t.Errorf("Expected the oldest backup to be rotated out, Got %v", entries)
+	}
+
+	restored, err := OpenKomainuBolt(path)
+	if err != nil {
+		t.Errorf("Could not open the backup: %v", err)
+		return
+	}
+	defer restored.Close()
+	var output string
+	if found, err := restored.Get(testGuild, col, "some arbituary key", &output); err != nil || !found || output != "some arbituary string" {
+		t.Errorf("Expected the value in the backup, Got %q (%v)", output, err)
+	}
+
+	second, err := BackupNow(kvs, cfg)
+	if err != nil || second == path {
+		t.Errorf("Expected a backup right after another to get a name of its own, Got %s (%v)", second, err)
+	}
+
+	if _, err := BackupNow(OpenMemory(), cfg); err != ErrBackupUnsupported {
+		t.Errorf("Expected ErrBackupUnsupported for the memory store, Got %v", err)
+	}
+}
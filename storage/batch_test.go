@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBatchRollback(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		if err := kvs.Set(testGuild, col, "kept", "original"); err != nil {
+			t.Errorf("Could not set test input value: %v", err)
+			return
+		}
+
+		failure := errors.New("on purpose")
+		err := kvs.Batch(func(tx Tx) error {
+			if err := tx.Set(testGuild, col, "kept", "changed"); err != nil {
+				return err
+			}
+			if err := tx.Set(testGuild, col, "new", "value"); err != nil {
+				return err
+			}
+			var inside string
+			if _, err := tx.Get(testGuild, col, "new", &inside); err != nil || inside != "value" {
+				t.Errorf("Expected to read back the new value inside the batch, Got %q (%v)", inside, err)
+			}
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Errorf("Expected the batch error back, Got %v", err)
+		}
+
+		var output string
+		if _, err := kvs.Get(testGuild, col, "kept", &output); err != nil || output != "original" {
+			t.Errorf("Expected the original value after rollback, Got %q (%v)", output, err)
+		}
+		if exist, _ := kvs.Get(testGuild, col, "new", &output); exist {
+			t.Error("The new value survived the rollback")
+		}
+
+		err = kvs.Batch(func(tx Tx) error {
+			if err := tx.Delete(testGuild, col, "kept"); err != nil {
+				return err
+			}
+			return tx.Set(testGuild, col, "new", "value")
+		})
+		if err != nil {
+			t.Errorf("Batch failed: %v", err)
+			return
+		}
+		keys, _ := kvs.Keys(testGuild, col)
+		if len(keys) != 1 || keys[0] != "new" {
+			t.Errorf("Expected [new], Got %v", keys)
+		}
+	})
+}
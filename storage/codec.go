@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Codec turns values into bytes and back again.
+// Every stored value starts with the Marker of the codec that encoded it, so values can always be decoded, whatever codec is in use now.
+type Codec interface {
+	Marker() byte
+	Encode(value any) ([]byte, error)
+	Decode(raw []byte, out any) error
+}
+
+// Markers are picked from 0x80 to 0xF7, as a gob stream never starts with one of those.
+// That way, values stored before markers existed are told apart, and decoded as the plain gob they are.
+const (
+	gobMarker  byte = 0x80
+	jsonMarker byte = 0x81
+)
+
+// codecs holds every Codec by the name used to select it in the Configuration.
+var codecs = map[string]Codec{
+	"gob":  gobCodec{},
+	"json": jsonCodec{},
+}
+
+// valueCodec is the Codec new values are encoded with.
+var valueCodec Codec = gobCodec{}
+
+// CodecNames returns the names of the available codecs, in alphabetical order.
+func CodecNames() []string {
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetValueCodec selects the codec new values are encoded with. Values already stored are left as they are.
+func SetValueCodec(name string) error {
+	codec, ok := codecs[name]
+	if !ok {
+		return fmt.Errorf("codec %q is not one of %v", name, CodecNames())
+	}
+	valueCodec = codec
+	return nil
+}
+
+// encodeValue encodes the given value the way every KeyValueStore stores it.
+func encodeValue(value any) ([]byte, error) {
+	encoded, err := valueCodec.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{valueCodec.Marker()}, encoded...), nil
+}
+
+// decodeValue decodes a stored value into the given reference, using whatever codec it was encoded with.
+func decodeValue(raw []byte, out any) error {
+	if len(raw) > 0 {
+		for _, codec := range codecs {
+			if raw[0] == codec.Marker() {
+				return codec.Decode(raw[1:], out)
+			}
+		}
+	}
+	return gobCodec{}.Decode(raw, out) // From before there were markers.
+}
+
+// gobCodec is compact and handles anything, but can't be read by anything but Go, and is picky about types changing.
+type gobCodec struct{}
+
+func (gobCodec) Marker() byte {
+	return gobMarker
+}
+
+func (gobCodec) Encode(value any) ([]byte, error) {
+	var inputBuffer bytes.Buffer
+	if err := gob.NewEncoder(&inputBuffer).Encode(value); err != nil {
+		return nil, fmt.Errorf("unable to encode raw value %v as gob for Set: %w", value, err)
+	}
+	return inputBuffer.Bytes(), nil
+}
+
+func (gobCodec) Decode(raw []byte, out any) error {
+	return gob.NewDecoder(bytes.NewReader(raw)).Decode(out)
+}
+
+// jsonCodec can be read with any old bolt or SQLite tool, and shrugs off added or removed fields.
+type jsonCodec struct{}
+
+func (jsonCodec) Marker() byte {
+	return jsonMarker
+}
+
+func (jsonCodec) Encode(value any) ([]byte, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode raw value %v as JSON for Set: %w", value, err)
+	}
+	return encoded, nil
+}
+
+func (jsonCodec) Decode(raw []byte, out any) error {
+	return json.Unmarshal(raw, out)
+}
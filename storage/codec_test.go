@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+func TestCodecSwitch(t *testing.T) {
+	t.Cleanup(func() {
+		SetValueCodec("gob")
+	})
+
+	type SomeTestStruct struct {
+		First  string
+		Second int
+	}
+	input := SomeTestStruct{"foo", 123}
+
+	var unmarked bytes.Buffer
+	if err := gob.NewEncoder(&unmarked).Encode(input); err != nil {
+		t.Errorf("Could not gob encode test input: %v", err)
+		return
+	}
+	gobbed, err := encodeValue(input)
+	if err != nil {
+		t.Errorf("Could not encode as gob: %v", err)
+		return
+	}
+	if err := SetValueCodec("json"); err != nil {
+		t.Errorf("Could not select the JSON codec: %v", err)
+		return
+	}
+	jsoned, err := encodeValue(input)
+	if err != nil {
+		t.Errorf("Could not encode as JSON: %v", err)
+		return
+	}
+	if string(jsoned[1:]) != `{"First":"foo","Second":123}` {
+		t.Errorf("Expected readable JSON after the marker, Got %q", jsoned)
+	}
+
+	for name, raw := range map[string][]byte{"unmarked gob": unmarked.Bytes(), "gob": gobbed, "json": jsoned} {
+		var output SomeTestStruct
+		if err := decodeValue(raw, &output); err != nil {
+			t.Errorf("Could not decode %s: %v", name, err)
+			continue
+		}
+		if input != output {
+			t.Errorf("%s: Expected %v, Got %v", name, input, output)
+		}
+	}
+
+	if err := SetValueCodec("xml"); err == nil {
+		t.Error("Expected an error for an unknown codec")
+	}
+}
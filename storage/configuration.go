@@ -1,6 +1,18 @@
 package storage
 
-import "log"
+import (
+	"errors"
+	"fmt"
+	"komainu/utility"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
 
 // GetConfiguration gets a freshly loaded configuration.
 func GetConfiguration() Configuration {
@@ -12,7 +24,349 @@ func GetConfiguration() Configuration {
 }
 
 type Configuration struct {
-	Logfile string
+	Logfile  string
+	LogLevel string // "debug" logs everything, "info" leaves out the chatter about every single message.
+	DevMode  bool   // Registers commands with DevGuildIDs only, and makes the log more detailed.
+	Storage  string // Which backend to keep everything in: "bolt", "sqlite", or "memory" for a throwaway session.
+	Database string // Where the database lives. Blank means the default for the Storage backend.
+	Codec    string // How new values are encoded: "gob" or "json". Values encoded either way can always be read.
+	// TokenSource is where to find the bot token: "env:NAME" reads the environment variable NAME, "file:path" reads a file.
+	TokenSource string
+	OwnerIDs    []discord.UserID  // Bot owners, on top of whoever owns the application.
+	DevGuildIDs []discord.GuildID // Guilds to register commands with in dev mode, instead of globally.
+	// ErrorChannel is where the stack traces of crashed handlers are posted, for the bot owners to look at. Unset means they're only logged.
+	ErrorChannel discord.ChannelID
+	Throttle     ThrottleConfiguration
+	Intervals    IntervalConfiguration
+	Backup       BackupConfiguration
+	// PurgeAfter is how long to keep a guild's data after being removed from it, like "168h". "0" purges on the next sweep.
+	PurgeAfter Duration
+	// AuditRetention is how long audit log entries are kept, like "2160h". "0" keeps them forever.
+	AuditRetention Duration
+}
+
+// StorageBackends lists the values Storage can have.
+var StorageBackends = []string{"bolt", "sqlite", "memory"}
+
+// LogLevels lists the values LogLevel can have.
+var LogLevels = []string{"debug", "info"}
+
+// Duration is a time.Duration written the way humans do, like "10m" or "24h".
+type Duration string
+
+// Parse turns the Duration into a time.Duration.
+func (d Duration) Parse() (time.Duration, error) {
+	return time.ParseDuration(string(d))
+}
+
+// Value is the time.Duration, for a Duration that was already validated. Anything invalid is zero.
+func (d Duration) Value() time.Duration {
+	value, _ := d.Parse()
+	return value
+}
+
+// ThrottleConfiguration controls how many commands can be used before being told to calm down.
+// Guilds can change the limits every command shares for themselves, see the throttle settings.
+type ThrottleConfiguration struct {
+	ThrottleLimits
+	// Commands gives commands limits of their own by name, counted apart from everything else.
+	// Anything left blank is taken from the limits every command shares.
+	Commands map[string]ThrottleLimits `json:",omitempty"`
+}
+
+// ThrottleLimits are the limits for using commands.
+type ThrottleLimits struct {
+	UserMax    int      // Commands a user can use per Interval.
+	ChannelMax int      // Commands that can be used in a channel per Interval.
+	Interval   Duration // What the maximums are counted over. Used commands are forgotten steadily, all of them over one Interval.
+}
+
+// CommandLimits returns the limits for the named command, and if it has limits of its own.
+func (throttle ThrottleConfiguration) CommandLimits(name string) (limits ThrottleLimits, own bool) {
+	limits, own = throttle.Commands[name]
+	if !own {
+		return throttle.ThrottleLimits, false
+	}
+	if limits.UserMax == 0 {
+		limits.UserMax = throttle.UserMax
+	}
+	if limits.ChannelMax == 0 {
+		limits.ChannelMax = throttle.ChannelMax
+	}
+	if limits.Interval == "" {
+		limits.Interval = throttle.Interval
+	}
+	return limits, true
+}
+
+// User is the limit for each user.
+func (limits ThrottleLimits) User() utility.Limit {
+	return utility.Limit{Max: limits.UserMax, Interval: limits.Interval.Value()}
+}
+
+// Channel is the limit for each channel.
+func (limits ThrottleLimits) Channel() utility.Limit {
+	return utility.Limit{Max: limits.ChannelMax, Interval: limits.Interval.Value()}
+}
+
+// IntervalConfiguration controls how often the background work happens.
+type IntervalConfiguration struct {
+	Sweep      Duration // Removing expired keys, which is also when votes close.
+	SeenFlush  Duration // Writing buffered seen timestamps.
+	ActiveRole Duration // Revoking the active role from those gone quiet.
+}
+
+// BackupConfiguration controls the scheduled backups of the database.
+type BackupConfiguration struct {
+	Interval  Duration // Set it to "0" to turn scheduled backups off.
+	Directory string
+	Keep      int
+}
+
+// envOverrides lists the environment variables that override settings, and how each is applied.
+// They are applied after the file is loaded, and never saved to it.
+var envOverrides = []struct {
+	name  string
+	apply func(c *Configuration, value string) error
+}{
+	{"LOGFILE", func(c *Configuration, value string) error { c.Logfile = value; return nil }},
+	{"LOG_LEVEL", func(c *Configuration, value string) error { c.LogLevel = value; return nil }},
+	{"DEV_MODE", func(c *Configuration, value string) error { c.DevMode = value != ""; return nil }},
+	{"STORAGE", func(c *Configuration, value string) error { c.Storage = value; return nil }},
+	{"DATABASE", func(c *Configuration, value string) error { c.Database = value; return nil }},
+	{"CODEC", func(c *Configuration, value string) error { c.Codec = value; return nil }},
+	{"TOKEN_SOURCE", func(c *Configuration, value string) error { c.TokenSource = value; return nil }},
+	{"OWNER_IDS", func(c *Configuration, value string) (err error) {
+		c.OwnerIDs, err = parseIDs[discord.UserID](value)
+		return
+	}},
+	{"DEV_GUILD_IDS", func(c *Configuration, value string) (err error) {
+		c.DevGuildIDs, err = parseIDs[discord.GuildID](value)
+		return
+	}},
+	{"ERROR_CHANNEL", func(c *Configuration, value string) error {
+		if strings.TrimSpace(value) == "" {
+			c.ErrorChannel = discord.NullChannelID
+			return nil
+		}
+		snowflake, err := discord.ParseSnowflake(strings.TrimSpace(value))
+		c.ErrorChannel = discord.ChannelID(snowflake)
+		return err
+	}},
+	{"THROTTLE_USER_MAX", func(c *Configuration, value string) (err error) {
+		c.Throttle.UserMax, err = strconv.Atoi(value)
+		return
+	}},
+	{"THROTTLE_CHANNEL_MAX", func(c *Configuration, value string) (err error) {
+		c.Throttle.ChannelMax, err = strconv.Atoi(value)
+		return
+	}},
+	{"THROTTLE_INTERVAL", func(c *Configuration, value string) error { c.Throttle.Interval = Duration(value); return nil }},
+	{"SWEEP_INTERVAL", func(c *Configuration, value string) error { c.Intervals.Sweep = Duration(value); return nil }},
+	{"SEEN_FLUSH_INTERVAL", func(c *Configuration, value string) error { c.Intervals.SeenFlush = Duration(value); return nil }},
+	{"ACTIVE_ROLE_INTERVAL", func(c *Configuration, value string) error { c.Intervals.ActiveRole = Duration(value); return nil }},
+	{"BACKUP_INTERVAL", func(c *Configuration, value string) error { c.Backup.Interval = Duration(value); return nil }},
+	{"BACKUP_DIRECTORY", func(c *Configuration, value string) error { c.Backup.Directory = value; return nil }},
+	{"BACKUP_KEEP", func(c *Configuration, value string) (err error) {
+		c.Backup.Keep, err = strconv.Atoi(value)
+		return
+	}},
+	{"PURGE_AFTER", func(c *Configuration, value string) error { c.PurgeAfter = Duration(value); return nil }},
+	{"AUDIT_RETENTION", func(c *Configuration, value string) error { c.AuditRetention = Duration(value); return nil }},
+}
+
+// parseIDs turns a comma separated list of IDs into a slice.
+func parseIDs[ID ~uint64](raw string) (ids []ID, err error) {
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		snowflake, err := discord.ParseSnowflake(part)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid ID: %w", part, err)
+		}
+		ids = append(ids, ID(snowflake))
+	}
+	return ids, nil
+}
+
+// applyEnv applies any environment variables that override settings.
+func (c *Configuration) applyEnv() error {
+	for _, override := range envOverrides {
+		value, set := os.LookupEnv(override.name)
+		if !set {
+			continue
+		}
+		if err := override.apply(c, value); err != nil {
+			return fmt.Errorf("environment variable %s: %w", override.name, err)
+		}
+	}
+	return nil
+}
+
+// applyDefaults fills in anything left blank, so older configuration files keep working.
+func (c *Configuration) applyDefaults() {
+	if c.LogLevel == "" {
+		c.LogLevel = "info"
+	}
+	if c.Storage == "" {
+		c.Storage = "bolt"
+	}
+	if c.Codec == "" {
+		c.Codec = "gob"
+	}
+	if c.TokenSource == "" {
+		c.TokenSource = "env:BOT_TOKEN"
+	}
+	if c.Throttle.UserMax == 0 {
+		c.Throttle.UserMax = 5
+	}
+	if c.Throttle.ChannelMax == 0 {
+		c.Throttle.ChannelMax = 10
+	}
+	if c.Throttle.Interval == "" {
+		c.Throttle.Interval = "10s"
+	}
+	if c.Intervals.Sweep == "" {
+		c.Intervals.Sweep = "1m"
+	}
+	if c.Intervals.SeenFlush == "" {
+		c.Intervals.SeenFlush = "30s"
+	}
+	if c.Intervals.ActiveRole == "" {
+		c.Intervals.ActiveRole = "10m"
+	}
+	if c.PurgeAfter == "" {
+		c.PurgeAfter = "168h"
+	}
+	if c.AuditRetention == "" {
+		c.AuditRetention = "2160h"
+	}
+	if c.Backup.Interval == "" {
+		c.Backup.Interval = "24h"
+	}
+	if c.Backup.Directory == "" {
+		c.Backup.Directory = "data/backups"
+	}
+	if c.Backup.Keep <= 0 {
+		c.Backup.Keep = 7
+	}
+}
+
+// validate makes sure everything that was loaded makes sense, listing every problem found.
+func (c *Configuration) validate() error {
+	problems := []string{}
+	if !contains(LogLevels, c.LogLevel) {
+		problems = append(problems, fmt.Sprintf("log level %q is not one of %v", c.LogLevel, LogLevels))
+	}
+	if !contains(StorageBackends, c.Storage) {
+		problems = append(problems, fmt.Sprintf("storage %q is not one of %v", c.Storage, StorageBackends))
+	}
+	if _, known := codecs[c.Codec]; !known {
+		problems = append(problems, fmt.Sprintf("codec %q is not one of %v", c.Codec, CodecNames()))
+	}
+	if kind, _, _ := strings.Cut(c.TokenSource, ":"); kind != "env" && kind != "file" {
+		problems = append(problems, fmt.Sprintf("token source %q should start with env: or file:", c.TokenSource))
+	}
+	for _, ownerID := range c.OwnerIDs {
+		if !ownerID.IsValid() {
+			problems = append(problems, fmt.Sprintf("owner ID %q is not a valid user ID", ownerID))
+		}
+	}
+	for _, guildID := range c.DevGuildIDs {
+		if !guildID.IsValid() {
+			problems = append(problems, fmt.Sprintf("dev guild ID %q is not a valid guild ID", guildID))
+		}
+	}
+	if c.Throttle.UserMax < 1 || c.Throttle.ChannelMax < 1 {
+		problems = append(problems, "throttle maximums must be at least 1")
+	}
+	if c.Backup.Keep < 1 {
+		problems = append(problems, "backups to keep must be at least 1")
+	}
+	durations := map[string]Duration{
+		"throttle interval":    c.Throttle.Interval,
+		"sweep interval":       c.Intervals.Sweep,
+		"seen flush interval":  c.Intervals.SeenFlush,
+		"active role interval": c.Intervals.ActiveRole,
+		"backup interval":      c.Backup.Interval,
+		"purge grace period":   c.PurgeAfter,
+		"audit retention":      c.AuditRetention,
+	}
+	for name, limits := range c.Throttle.Commands {
+		if limits.UserMax < 0 || limits.ChannelMax < 0 {
+			problems = append(problems, fmt.Sprintf("throttle maximums for /%s can't be negative", name))
+		}
+		if limits.Interval != "" {
+			durations["throttle interval for /"+name] = limits.Interval
+		}
+	}
+	for name, duration := range durations {
+		value, err := duration.Parse()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s %q is not a valid duration", name, duration))
+		} else if value < 0 {
+			problems = append(problems, fmt.Sprintf("%s %q can't be negative", name, duration))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// contains checks if the slice holds the value.
+func contains(slice []string, value string) bool {
+	for _, item := range slice {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// DatabasePath returns where the database lives, falling back to the default for the Storage backend.
+func (c *Configuration) DatabasePath() string {
+	if c.Database != "" {
+		return c.Database
+	}
+	if c.Storage == "sqlite" {
+		return "data/komainu.sqlite"
+	}
+	return "data/komainubolt"
+}
+
+// BotToken reads the bot token from the TokenSource.
+func (c *Configuration) BotToken() (string, error) {
+	kind, source, _ := strings.Cut(c.TokenSource, ":")
+	var token string
+	switch kind {
+	case "env":
+		token = os.Getenv(source)
+	case "file":
+		raw, err := os.ReadFile(source)
+		if err != nil {
+			return "", fmt.Errorf("could not read the token file: %w", err)
+		}
+		token = strings.TrimSpace(string(raw))
+	default:
+		return "", fmt.Errorf("unknown token source %q", c.TokenSource)
+	}
+	if token == "" {
+		return "", errors.New("no token found in " + c.TokenSource)
+	}
+	return token, nil
+}
+
+// IsOwner checks if the given user is listed in OwnerIDs.
+func (c *Configuration) IsOwner(userID discord.UserID) bool {
+	for _, ownerID := range c.OwnerIDs {
+		if ownerID == userID {
+			return true
+		}
+	}
+	return false
 }
 
 // Path returns the path to where the configuration is stored.
@@ -21,19 +375,94 @@ func (c *Configuration) Path() string {
 }
 
 // Load loads the configuration file, or creates one if none exists.
+// Environment variable overrides are applied on top, and the result is validated.
 func (c *Configuration) Load() error {
 	if exist, err := JSONFileExists(c); err != nil {
 		return err
 	} else if exist {
-		return LoadJSON(c)
+		if err := LoadJSON(c); err != nil {
+			return err
+		}
+		c.applyDefaults()
 	} else {
 		log.Println("Configuration file not found, will create a new one!")
 		c.Logfile = "komainu.log"
-		return c.Save()
+		c.applyDefaults()
+		if err := c.Save(); err != nil {
+			return err
+		}
+	}
+	if err := c.applyEnv(); err != nil {
+		return err
 	}
+	return c.validate()
 }
 
 // Save, in a shocking turn of events, saves the configuration file.
 func (c *Configuration) Save() error {
 	return SaveJSON(c)
 }
+
+// current is the configuration in use, which ReloadConfiguration may replace while running.
+var current = struct {
+	mutex sync.RWMutex
+	cfg   Configuration
+}{}
+
+// CurrentConfiguration returns a copy of the configuration in use.
+func CurrentConfiguration() Configuration {
+	current.mutex.RLock()
+	defer current.mutex.RUnlock()
+	return current.cfg
+}
+
+// SetCurrentConfiguration makes the given configuration the one in use.
+func SetCurrentConfiguration(cfg Configuration) {
+	current.mutex.Lock()
+	defer current.mutex.Unlock()
+	current.cfg = cfg
+}
+
+// ReloadConfiguration loads the configuration again, and makes it the one in use if it's valid.
+// Settings only used when connecting can't change without a restart, so they are kept as they were.
+func ReloadConfiguration() (Configuration, error) {
+	reloaded := Configuration{}
+	if err := reloaded.Load(); err != nil {
+		return CurrentConfiguration(), fmt.Errorf("kept the old configuration, as the new one failed to load: %w", err)
+	}
+	current.mutex.Lock()
+	defer current.mutex.Unlock()
+	old := current.cfg
+	restartOnly := map[string]bool{
+		"Logfile":     reloaded.Logfile != old.Logfile,
+		"Storage":     reloaded.Storage != old.Storage,
+		"Database":    reloaded.Database != old.Database,
+		"Codec":       reloaded.Codec != old.Codec,
+		"TokenSource": reloaded.TokenSource != old.TokenSource,
+		"DevGuildIDs": fmt.Sprint(reloaded.DevGuildIDs) != fmt.Sprint(old.DevGuildIDs),
+	}
+	for name, changed := range restartOnly {
+		if changed {
+			log.Printf("Configuration reload: %s changed, but that needs a restart to take effect", name)
+		}
+	}
+	reloaded.Logfile, reloaded.Storage, reloaded.Database, reloaded.Codec, reloaded.TokenSource, reloaded.DevGuildIDs =
+		old.Logfile, old.Storage, old.Database, old.Codec, old.TokenSource, old.DevGuildIDs
+	current.cfg = reloaded
+	return reloaded, nil
+}
+
+// tickEvery calls fn every time the interval picked from the current configuration has passed.
+// The interval is picked again after every tick, so a reloaded configuration takes effect.
+// An interval of zero means fn is not called, until the configuration says otherwise.
+func tickEvery(pick func(cfg Configuration) time.Duration, fn func()) {
+	for {
+		interval := pick(CurrentConfiguration())
+		if interval <= 0 {
+			time.Sleep(time.Minute)
+			continue
+		}
+		time.Sleep(interval)
+		fn()
+	}
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+func TestConfigurationEnvOverrides(t *testing.T) {
+	t.Setenv("STORAGE", "sqlite")
+	t.Setenv("OWNER_IDS", "123, 456")
+	t.Setenv("THROTTLE_USER_MAX", "3")
+	t.Setenv("SWEEP_INTERVAL", "5m")
+	t.Setenv("ERROR_CHANNEL", "789")
+
+	cfg := Configuration{Storage: "bolt"}
+	cfg.applyDefaults()
+	if err := cfg.applyEnv(); err != nil {
+		t.Errorf("Could not apply environment: %v", err)
+		return
+	}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("Expected a valid configuration, Got %v", err)
+	}
+	if cfg.Storage != "sqlite" || cfg.DatabasePath() != "data/komainu.sqlite" {
+		t.Errorf("Expected sqlite at its default path, Got %s at %s", cfg.Storage, cfg.DatabasePath())
+	}
+	if !cfg.IsOwner(discord.UserID(456)) || cfg.IsOwner(discord.UserID(789)) {
+		t.Errorf("Expected owners 123 and 456, Got %v", cfg.OwnerIDs)
+	}
+	if cfg.Throttle.UserMax != 3 || cfg.Intervals.Sweep.Value() != 5*time.Minute {
+		t.Errorf("Expected overridden throttle and interval, Got %d and %s", cfg.Throttle.UserMax, cfg.Intervals.Sweep)
+	}
+	if cfg.ErrorChannel != discord.ChannelID(789) {
+		t.Errorf("Expected error channel 789, Got %s", cfg.ErrorChannel)
+	}
+
+	t.Setenv("THROTTLE_USER_MAX", "lots")
+	if err := cfg.applyEnv(); err == nil || !strings.Contains(err.Error(), "THROTTLE_USER_MAX") {
+		t.Errorf("Expected an error naming the bad variable, Got %v", err)
+	}
+}
+
+func TestThrottleCommandLimits(t *testing.T) {
+	cfg := Configuration{}
+	cfg.applyDefaults()
+	cfg.Throttle.Commands = map[string]ThrottleLimits{"vote": {UserMax: 1, Interval: "1m"}}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("Expected a valid configuration, Got %v", err)
+		return
+	}
+	limits, own := cfg.Throttle.CommandLimits("vote")
+	if !own || limits.User().Max != 1 || limits.Channel().Max != 10 || limits.User().Interval != time.Minute {
+		t.Errorf("Expected /vote limits of its own, with the rest filled in, Got %+v (own: %t)", limits, own)
+	}
+	if limits, own := cfg.Throttle.CommandLimits("faq"); own || limits != cfg.Throttle.ThrottleLimits {
+		t.Errorf("Expected /faq to use the shared limits, Got %+v (own: %t)", limits, own)
+	}
+
+	cfg.Throttle.Commands["vote"] = ThrottleLimits{UserMax: -1, Interval: "sometimes"}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "/vote") {
+		t.Errorf("Expected an error about /vote, Got %v", err)
+	}
+}
+
+func TestConfigurationValidation(t *testing.T) {
+	cfg := Configuration{LogLevel: "shouty", Storage: "floppy", Codec: "morse", TokenSource: "carrier pigeon"}
+	cfg.applyDefaults()
+	cfg.Intervals.SeenFlush = "whenever"
+	cfg.Backup.Interval = "-1h"
+	err := cfg.validate()
+	if err == nil {
+		t.Error("Expected the configuration to be invalid")
+		return
+	}
+	for _, problem := range []string{"shouty", "floppy", "morse", "carrier pigeon", "whenever", "-1h"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected %q to be mentioned in %v", problem, err)
+		}
+	}
+}
+
+func TestBotToken(t *testing.T) {
+	t.Setenv("SOME_TEST_TOKEN", "from the environment")
+	cfg := Configuration{TokenSource: "env:SOME_TEST_TOKEN"}
+	if token, err := cfg.BotToken(); err != nil || token != "from the environment" {
+		t.Errorf("Expected the token from the environment, Got %q (%v)", token, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("from a file\n"), 0600); err != nil {
+		t.Errorf("Could not write token file: %v", err)
+		return
+	}
+	cfg.TokenSource = "file:" + path
+	if token, err := cfg.BotToken(); err != nil || token != "from a file" {
+		t.Errorf("Expected the token from the file, Got %q (%v)", token, err)
+	}
+
+	cfg.TokenSource = "env:SOME_MISSING_TEST_TOKEN"
+	if _, err := cfg.BotToken(); err == nil {
+		t.Error("Expected an error for a missing token")
+	}
+}
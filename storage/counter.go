@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// Counter is a key in a collection of counters, and its count.
+type Counter struct {
+	Key   string
+	Count int64
+}
+
+// increment adds delta to the int64 stored under the given key, treating a missing key as zero.
+// Every Tx implements Increment with this, as a Tx already keeps everyone else out.
+func increment(tx Tx, guildID discord.GuildID, collection string, key any, delta int64) (count int64, err error) {
+	if _, err := tx.Get(guildID, collection, key, &count); err != nil {
+		return 0, fmt.Errorf("could not read counter %s/%v: %w", collection, key, err)
+	}
+	count += delta
+	if err := tx.Set(guildID, collection, key, count); err != nil {
+		return 0, fmt.Errorf("could not write counter %s/%v: %w", collection, key, err)
+	}
+	return count, nil
+}
+
+// incrementInBatch runs a single Increment in its own Batch, for the KeyValueStore implementations.
+func incrementInBatch(kvs KeyValueStore, guildID discord.GuildID, collection string, key any, delta int64) (count int64, err error) {
+	err = kvs.Batch(func(tx Tx) (err error) {
+		count, err = tx.Increment(guildID, collection, key, delta)
+		return
+	})
+	return
+}
+
+// TopCounters returns the n highest counters in the collection, highest first. Ties are in key order.
+// An n of zero or less returns them all.
+func TopCounters(kvs KeyValueStore, guildID discord.GuildID, collection string, n int) (top []Counter, err error) {
+	err = kvs.ForEach(guildID, collection, func(key string, value ValueDecoder) error {
+		var count int64
+		if err := value(&count); err != nil {
+			return fmt.Errorf("could not decode counter %s/%s: %w", collection, key, err)
+		}
+		top = append(top, Counter{key, count})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(top, func(i, j int) bool { return top[i].Count > top[j].Count })
+	if n > 0 && len(top) > n {
+		top = top[:n]
+	}
+	return top, nil
+}
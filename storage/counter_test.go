@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestIncrement(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					if _, err := kvs.Increment(testGuild, col, "busy", 1); err != nil {
+						t.Errorf("Could not increment: %v", err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		var count int64
+		if _, err := kvs.Get(testGuild, col, "busy", &count); err != nil || count != 200 {
+			t.Errorf("Expected 200 after concurrent increments, Got %d (%v)", count, err)
+		}
+		if count, err := kvs.Increment(testGuild, col, "quiet", -3); err != nil || count != -3 {
+			t.Errorf("Expected a missing counter to start at zero, Got %d (%v)", count, err)
+		}
+		kvs.Increment(testGuild, col, "middling", 50)
+		kvs.Increment(testGuild, col, "also middling", 50)
+
+		top, err := TopCounters(kvs, testGuild, col, 3)
+		if err != nil {
+			t.Errorf("Could not get top counters: %v", err)
+			return
+		}
+		expected := []Counter{{"busy", 200}, {"also middling", 50}, {"middling", 50}}
+		if len(top) != len(expected) {
+			t.Errorf("Expected %v, Got %v", expected, top)
+			return
+		}
+		for i := range expected {
+			if top[i] != expected[i] {
+				t.Errorf("Expected %v, Got %v", expected[i], top[i])
+			}
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// dailyUseCollection counts how often things were used each day, so they can be capped. The counts expire when their day is over.
+const dailyUseCollection = "dailyuse"
+
+// UseDaily counts a use of whatever the key names, unless it was already used limit times today. Days start at midnight UTC.
+// When the limit was reached, it returns how long until it can be used again tomorrow.
+func UseDaily(kvs KeyValueStore, guildID discord.GuildID, key string, limit int) (ok bool, wait time.Duration, err error) {
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+	untilTomorrow := today.Add(24 * time.Hour).Sub(now)
+	dayKey := key + "/" + today.Format("2006-01-02")
+	err = kvs.Batch(func(tx Tx) error {
+		var count int
+		if _, err := tx.Get(guildID, dailyUseCollection, dayKey, &count); err != nil {
+			return fmt.Errorf("could not read daily use of %s: %w", key, err)
+		}
+		if count >= limit {
+			return nil
+		}
+		ok = true
+		return tx.SetWithTTL(guildID, dailyUseCollection, dayKey, count+1, untilTomorrow)
+	})
+	if err != nil || ok {
+		return ok, 0, err
+	}
+	return false, untilTomorrow, nil
+}
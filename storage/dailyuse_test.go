@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUseDaily(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		for i := 0; i < 2; i++ {
+			if ok, _, err := UseDaily(kvs, testGuild, "ateball/1", 2); !ok || err != nil {
+				t.Errorf("Expected use %d to be allowed, Got %t (%v)", i+1, ok, err)
+				return
+			}
+		}
+		ok, wait, err := UseDaily(kvs, testGuild, "ateball/1", 2)
+		if ok || err != nil || wait <= 0 || wait > 24*time.Hour {
+			t.Errorf("Expected the third use to wait until tomorrow, Got %t, %s (%v)", ok, wait, err)
+		}
+		if ok, _, _ := UseDaily(kvs, testGuild, "ateball/2", 2); !ok {
+			t.Error("Expected another key to be counted separately")
+		}
+		if ok, _, _ := UseDaily(kvs, testGuild, "ateball/3", 0); ok {
+			t.Error("Expected a limit of 0 to allow nothing")
+		}
+	})
+}
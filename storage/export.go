@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// collectionTypes maps each known collection to a function returning a fresh reference for whatever is stored under the given key.
+var collectionTypes = map[string]func(key string) any{
+	"faq":          func(string) any { return new(string) },
+	"votes":        func(string) any { return new(Vote) },
+	"seen":         func(string) any { return new(int64) },
+	"roleselector": func(string) any { return new(RoleSelector) },
+	"rolebutton":   func(string) any { return new(RoleButton) },
+	"activerole": func(key string) any {
+		if key == "days" {
+			return new(float64)
+		}
+		return new(discord.RoleID)
+	},
+	"deletelog":  func(string) any { return new(discord.ChannelID) },
+	"trafficlog": func(string) any { return new(discord.ChannelID) },
+	"settings": func(key string) any {
+		setting, ok := LookupSetting(key)
+		if !ok {
+			return nil
+		}
+		return setting.newValue()
+	},
+}
+
+// KnownCollections returns the names of the collections this version of Komainu knows how to decode, in alphabetical order.
+func KnownCollections() []string {
+	names := make([]string, 0, len(collectionTypes))
+	for name := range collectionTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newValueFor returns a fresh reference suitable for decoding the given key in the given collection into.
+// It isn't known if the collection isn't, or if it is the settings collection and no such setting is registered.
+func newValueFor(collection string, key string) (value any, known bool) {
+	maker, known := collectionTypes[collection]
+	if !known {
+		return nil, false
+	}
+	value = maker(key)
+	return value, value != nil
+}
+
+// GuildExport is the human readable representation of everything stored for a guild.
+type GuildExport struct {
+	GuildID     discord.GuildID
+	Exported    int64
+	Collections map[string]map[string]json.RawMessage
+}
+
+// ExportGuild decodes every known collection for the given guild into a GuildExport.
+func ExportGuild(kvs KeyValueStore, guildID discord.GuildID) (export GuildExport, err error) {
+	export = GuildExport{
+		GuildID:     guildID,
+		Exported:    time.Now().Unix(),
+		Collections: map[string]map[string]json.RawMessage{},
+	}
+	for _, collection := range KnownCollections() {
+		entries := map[string]json.RawMessage{}
+		err := kvs.ForEach(guildID, collection, func(key string, decode ValueDecoder) error {
+			value, known := newValueFor(collection, key)
+			if !known {
+				log.Printf("[%s] Export skipped %s/%s, as nothing by that name is registered", guildID, collection, key)
+				return nil
+			}
+			if err := decode(value); err != nil {
+				return fmt.Errorf("export could not decode %s/%s: %w", collection, key, err)
+			}
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("export could not encode %s/%s as JSON: %w", collection, key, err)
+			}
+			entries[key] = encoded
+			return nil
+		})
+		if err != nil {
+			return export, err
+		}
+		if len(entries) > 0 {
+			export.Collections[collection] = entries
+		}
+	}
+	return export, nil
+}
+
+// ImportGuild stores everything in the given GuildExport under the given guild, returning how many keys were written.
+// Existing keys are overwritten, but keys not mentioned in the export are left alone.
+// It all happens in a single Batch, so if anything fails, nothing is imported.
+func ImportGuild(kvs KeyValueStore, guildID discord.GuildID, export GuildExport) (count int, err error) {
+	err = kvs.Batch(func(tx Tx) error {
+		count = 0
+		for collection, entries := range export.Collections {
+			if _, known := collectionTypes[collection]; !known {
+				return fmt.Errorf("import does not know how to handle the %q collection", collection)
+			}
+			for key, raw := range entries {
+				value, known := newValueFor(collection, key)
+				if !known {
+					return fmt.Errorf("import does not know how to handle %s/%s", collection, key)
+				}
+				if err := json.Unmarshal(raw, value); err != nil {
+					return fmt.Errorf("import could not decode %s/%s: %w", collection, key, err)
+				}
+				if err := storeDecoded(tx, guildID, collection, key, value); err != nil {
+					return fmt.Errorf("import could not store %s/%s: %w", collection, key, err)
+				}
+				count++
+			}
+		}
+		return migrateSettings(tx, guildID) // Exports from before the settings collection have them where features used to keep them.
+	})
+	if err != nil {
+		count = 0
+	}
+	return count, err
+}
+
+// storeDecoded stores a value that came from outside the KeyValueStore, making sure anything that needs an expiry gets one.
+func storeDecoded(kvs Tx, guildID discord.GuildID, collection string, key string, value any) error {
+	if vote, ok := value.(*Vote); ok {
+		vote.GuildID = guildID
+		return vote.Store(kvs)
+	}
+	return kvs.Set(guildID, collection, key, value)
+}
+
+// WriteGuildExport writes the given export to the writer as indented JSON.
+func WriteGuildExport(w io.Writer, export GuildExport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(export)
+}
+
+// ReadGuildExport reads a GuildExport from the given reader.
+func ReadGuildExport(r io.Reader) (export GuildExport, err error) {
+	err = json.NewDecoder(r).Decode(&export)
+	return
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	source := OpenMemory()
+	vote := Vote{
+		StartTime: 100,
+		EndTime:   200,
+		GuildID:   testGuild,
+		ChannelID: discord.ChannelID(12345),
+		MessageID: discord.MessageID(67890),
+		Question:  "Pizza?",
+		Order:     []string{"vote/0", "vote/1"},
+		Options:   map[string]string{"vote/0": "Yes", "vote/1": "No"},
+		Votes:     map[discord.UserID]string{discord.UserID(42): "vote/0"},
+	}
+	if err := vote.Store(source); err != nil {
+		t.Errorf("Could not store vote: %v", err)
+		return
+	}
+	if err := source.Set(testGuild, "faq", "horseradish", "It's a root."); err != nil {
+		t.Errorf("Could not store FAQ item: %v", err)
+		return
+	}
+	if err := source.Set(testGuild, "activerole", "days", 30.5); err != nil { // Where exports from before the settings collection have it.
+		t.Errorf("Could not store activerole days: %v", err)
+		return
+	}
+	if err := activeRole.Set(source, testGuild, discord.RoleID(1234)); err != nil {
+		t.Errorf("Could not store the active role setting: %v", err)
+		return
+	}
+
+	export, err := ExportGuild(source, testGuild)
+	if err != nil {
+		t.Errorf("Could not export guild: %v", err)
+		return
+	}
+	var bt bytes.Buffer
+	if err := WriteGuildExport(&bt, export); err != nil {
+		t.Errorf("Could not write export: %v", err)
+		return
+	}
+	readBack, err := ReadGuildExport(&bt)
+	if err != nil {
+		t.Errorf("Could not read export back: %v", err)
+		return
+	}
+
+	target := OpenMemory()
+	count, err := ImportGuild(target, testGuild, readBack)
+	if err != nil {
+		t.Errorf("Could not import guild: %v", err)
+		return
+	}
+	if count != 4 {
+		t.Errorf("Expected 4 imported keys, Got %d", count)
+	}
+
+	exist, imported, err := GetVote(target, testGuild, vote.MessageID)
+	if err != nil || !exist {
+		t.Errorf("Could not read imported vote back: %v", err)
+		return
+	}
+	if imported.Question != vote.Question || imported.Votes[discord.UserID(42)] != "vote/0" || imported.ChannelID != vote.ChannelID {
+		t.Errorf("Expected %v, Got %v", vote, imported)
+	}
+	if roleID, isSet, err := activeRole.Get(target, testGuild); err != nil || !isSet || roleID != 1234 {
+		t.Errorf("Expected the active role setting to survive, Got %s (set: %t, %v)", roleID, isSet, err)
+	}
+	if after, isSet, err := activeRoleAfter.Get(target, testGuild); err != nil || !isSet || after != 732*time.Hour {
+		t.Errorf("Expected the old activerole days to become a 732h setting, Got %s (set: %t, %v)", after, isSet, err)
+	}
+	if exist, _ := target.Get(testGuild, "activerole", "days", new(float64)); exist {
+		t.Error("Expected the old activerole days to be moved, not copied")
+	}
+}
@@ -2,9 +2,11 @@ package storage
 
 import (
 	"bytes"
-	"encoding/gob"
 	"errors"
 	"fmt"
+	"log"
+	"sync"
+	"time"
 
 	"github.com/diamondburned/arikawa/v3/discord"
 	bolt "go.etcd.io/bbolt"
@@ -12,6 +14,10 @@ import (
 
 type komainuBolt struct {
 	bolt *bolt.DB
+	path string // Where the database lives. After a Compact, bolt.Path() is where the compacted file was made.
+	// swap is held for reading by everything using the bolt DB, and for writing while Compact replaces it.
+	swap sync.RWMutex
+	watchers
 }
 
 func OpenKomainuBolt(path string) (*komainuBolt, error) {
@@ -19,16 +25,51 @@ func OpenKomainuBolt(path string) (*komainuBolt, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &komainuBolt{
-		newBolt,
-	}, nil
+	kb := &komainuBolt{
+		bolt: newBolt,
+		path: path,
+	}
+	guilds, err := kb.guilds()
+	if err != nil {
+		kb.Close()
+		return nil, fmt.Errorf("could not list guilds for schema migration: %w", err)
+	}
+	if err := MigrateSchemas(kb, guilds, SchemaDryRun); err != nil {
+		kb.Close()
+		return nil, err
+	}
+	return kb, nil
+}
+
+// guilds lists the guilds that have anything stored at all.
+func (kb *komainuBolt) guilds() (guilds []discord.GuildID, err error) {
+	kb.swap.RLock()
+	defer kb.swap.RUnlock()
+	err = kb.bolt.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			snowflake, err := discord.ParseSnowflake(string(name))
+			if err != nil {
+				log.Printf("Ignoring bucket %q, as it is not a guild ID", name)
+				return nil
+			}
+			guilds = append(guilds, discord.GuildID(snowflake))
+			return nil
+		})
+	})
+	return
 }
 
 func (kb *komainuBolt) createBucket(transaction *bolt.Tx, guild []byte, collection []byte) (bucket *bolt.Bucket, err error) {
+	isNewGuild := transaction.Bucket(guild) == nil
 	guildBucket, err := transaction.CreateBucketIfNotExists(guild)
 	if err != nil {
 		return nil, fmt.Errorf("bolt store failed to create guild bucket: %w", err)
 	}
+	if isNewGuild {
+		if err := kb.stampSchemaVersion(guildBucket); err != nil {
+			return nil, err
+		}
+	}
 	bucket, err = guildBucket.CreateBucketIfNotExists(collection)
 	if err != nil {
 		return nil, fmt.Errorf("bolt store failed to create collection bucket: %w", err)
@@ -36,6 +77,22 @@ func (kb *komainuBolt) createBucket(transaction *bolt.Tx, guild []byte, collecti
 	return
 }
 
+// stampSchemaVersion records a brand new guild as already being at the current schema version, so no migrations are run on it.
+func (kb *komainuBolt) stampSchemaVersion(guildBucket *bolt.Bucket) error {
+	schemaBucket, err := guildBucket.CreateBucketIfNotExists([]byte(schemaCollection))
+	if err != nil {
+		return fmt.Errorf("bolt store failed to create schema bucket: %w", err)
+	}
+	version, err := encodeValue(CurrentSchemaVersion())
+	if err != nil {
+		return err
+	}
+	if err := schemaBucket.Put([]byte(schemaVersionKey), version); err != nil {
+		return fmt.Errorf("bolt store failed to stamp schema version: %w", err)
+	}
+	return nil
+}
+
 func (kb *komainuBolt) getBucket(transaction *bolt.Tx, guild []byte, collection []byte) (bucket *bolt.Bucket) {
 	guildBucket := transaction.Bucket(guild)
 	if guildBucket == nil {
@@ -44,118 +101,326 @@ func (kb *komainuBolt) getBucket(transaction *bolt.Tx, guild []byte, collection
 	return guildBucket.Bucket(collection)
 }
 
-func (kb *komainuBolt) store(guild []byte, collection []byte, key []byte, value []byte) (err error) {
-	return kb.bolt.Update(func(tx *bolt.Tx) (err error) {
-		if tx == nil {
-			return errors.New("storage failed to open Update transaction")
-		}
-		bucket, err := kb.createBucket(tx, guild, collection)
-		if err != nil {
-			return
-		}
-		err = bucket.Put(key, value)
-		if err != nil {
-			err = fmt.Errorf("bolt store failed to Put value: %w", err)
-		}
+// store puts the value in bolt. If expires is non-zero, the key is due to expire at that unix time, otherwise any expiry is cleared.
+func (kb *komainuBolt) store(tx *bolt.Tx, guild []byte, collection []byte, key []byte, value []byte, expires int64) (err error) {
+	bucket, err := kb.createBucket(tx, guild, collection)
+	if err != nil {
 		return
-	})
+	}
+	err = bucket.Put(key, value)
+	if err != nil {
+		return fmt.Errorf("bolt store failed to Put value: %w", err)
+	}
+	return kb.setExpiry(tx, guild, collection, key, expires)
 }
 
-func (kb *komainuBolt) retrieve(guild []byte, collection []byte, key []byte) (found bool, value []byte, err error) {
-	err = kb.bolt.View(func(tx *bolt.Tx) (err error) {
-		if tx == nil {
-			return errors.New("storage failed to open View transaction")
-		}
-		bucket := kb.getBucket(tx, guild, collection)
+// setExpiry records when the given key expires, or forgets about it if expires is zero.
+func (kb *komainuBolt) setExpiry(tx *bolt.Tx, guild []byte, collection []byte, key []byte, expires int64) error {
+	expiryKeyb := []byte(expiryKey(string(collection), string(key)))
+	if expires == 0 {
+		bucket := kb.getBucket(tx, guild, []byte(expiryCollection))
 		if bucket == nil {
-			return
+			return nil
 		}
-		got := bucket.Get(key)
-		if got != nil {
-			found = true
-			value = make([]byte, len(got))
-			copy(value, got)
+		if err := bucket.Delete(expiryKeyb); err != nil {
+			return fmt.Errorf("bolt store failed to clear expiry: %w", err)
 		}
+		return nil
+	}
+	bucket, err := kb.createBucket(tx, guild, []byte(expiryCollection))
+	if err != nil {
+		return err
+	}
+	encoded, err := encodeValue(expires)
+	if err != nil {
+		return err
+	}
+	if err := bucket.Put(expiryKeyb, encoded); err != nil {
+		return fmt.Errorf("bolt store failed to Put expiry: %w", err)
+	}
+	return nil
+}
+
+// retrieve looks up a raw value. It is only valid for as long as the transaction is open.
+func (kb *komainuBolt) retrieve(tx *bolt.Tx, guild []byte, collection []byte, key []byte) (found bool, value []byte) {
+	bucket := kb.getBucket(tx, guild, collection)
+	if bucket == nil {
+		return
+	}
+	value = bucket.Get(key)
+	return value != nil, value
+}
+
+func (kb *komainuBolt) remove(tx *bolt.Tx, guild []byte, collection []byte, key []byte) (err error) {
+	bucket := kb.getBucket(tx, guild, collection)
+	if bucket == nil {
 		return
+	}
+	err = bucket.Delete(key)
+	if err != nil {
+		return fmt.Errorf("storage failed to delete: %w", err)
+	}
+	return kb.setExpiry(tx, guild, collection, key, 0)
+}
+
+func (kb *komainuBolt) keys(tx *bolt.Tx, guild []byte, collection []byte) (keys []string) {
+	bucket := kb.getBucket(tx, guild, collection)
+	if bucket == nil {
+		return
+	}
+	bucket.ForEach(func(k, _ []byte) error {
+		keys = append(keys, string(k))
+		return nil
 	})
 	return
 }
 
-func (kb *komainuBolt) remove(guild []byte, collection []byte, key []byte) (err error) {
-	err = kb.bolt.Update(func(tx *bolt.Tx) (err error) {
-		if tx == nil {
-			return errors.New("storage failed to open Delete transaction")
+// scan walks the keys starting with prefix using a cursor, calling fn for at most limit of them.
+func (kb *komainuBolt) scan(tx *bolt.Tx, guild []byte, collection []byte, prefix []byte, limit int, fn ForEachFunc) error {
+	bucket := kb.getBucket(tx, guild, collection)
+	if bucket == nil {
+		return nil
+	}
+	cursor := bucket.Cursor()
+	count := 0
+	for k, v := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+		if v == nil {
+			continue // Nested bucket, not a value.
 		}
-		bucket := kb.getBucket(tx, guild, collection)
-		if bucket == nil {
-			return
+		if limit > 0 && count >= limit {
+			break
 		}
-		err = bucket.Delete(key)
-		if err != nil {
-			return fmt.Errorf("storage failed to delete: %w", err)
+		value := v
+		if err := fn(string(k), func(out any) error { return decodeValue(value, out) }); err != nil {
+			return err
 		}
+		count++
+	}
+	return nil
+}
+
+func (kb *komainuBolt) key(raw any) []byte {
+	return []byte(keyString(raw))
+}
+
+// keyString turns almost any key into the string form used to store it.
+func keyString(raw any) string {
+	return fmt.Sprintf("%v", raw)
+}
+
+// komainuBoltTx is a Tx backed by a single bolt transaction.
+type komainuBoltTx struct {
+	kb      *komainuBolt
+	tx      *bolt.Tx
+	changes []ChangeEvent
+}
+
+func (kbt *komainuBoltTx) Set(guildID discord.GuildID, collection string, key any, value any) (err error) {
+	encoded, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+	if err := kbt.kb.store(kbt.tx, []byte(guildID.String()), []byte(collection), kbt.kb.key(key), encoded, 0); err != nil {
+		return err
+	}
+	kbt.changes = append(kbt.changes, ChangeEvent{guildID, collection, keyString(key), false})
+	return nil
+}
+
+func (kbt *komainuBoltTx) SetWithTTL(guildID discord.GuildID, collection string, key any, value any, ttl time.Duration) (err error) {
+	encoded, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+	if err := kbt.kb.store(kbt.tx, []byte(guildID.String()), []byte(collection), kbt.kb.key(key), encoded, expiryTime(ttl)); err != nil {
+		return err
+	}
+	kbt.changes = append(kbt.changes, ChangeEvent{guildID, collection, keyString(key), false})
+	return nil
+}
+
+func (kbt *komainuBoltTx) Get(guildID discord.GuildID, collection string, key any, out any) (found bool, err error) {
+	found, raw := kbt.kb.retrieve(kbt.tx, []byte(guildID.String()), []byte(collection), kbt.kb.key(key))
+	if !found {
 		return
-	})
+	}
+	err = decodeValue(raw, out)
 	return
 }
 
-func (kb *komainuBolt) keys(guild []byte, collection []byte) (keys []string, err error) {
-	err = kb.bolt.View(func(tx *bolt.Tx) (err error) {
-		bucket := kb.getBucket(tx, guild, collection)
-		if bucket == nil {
-			return
+func (kbt *komainuBoltTx) Delete(guildID discord.GuildID, collection string, key any) (err error) {
+	if err := kbt.kb.remove(kbt.tx, []byte(guildID.String()), []byte(collection), kbt.kb.key(key)); err != nil {
+		return err
+	}
+	kbt.changes = append(kbt.changes, ChangeEvent{guildID, collection, keyString(key), true})
+	return nil
+}
+
+func (kbt *komainuBoltTx) Keys(guildID discord.GuildID, collection string) (keys []string, err error) {
+	return kbt.kb.keys(kbt.tx, []byte(guildID.String()), []byte(collection)), nil
+}
+
+func (kbt *komainuBoltTx) ForEach(guildID discord.GuildID, collection string, fn ForEachFunc) (err error) {
+	return kbt.kb.scan(kbt.tx, []byte(guildID.String()), []byte(collection), nil, 0, fn)
+}
+
+func (kbt *komainuBoltTx) Scan(guildID discord.GuildID, collection string, prefix string, limit int, fn ForEachFunc) (err error) {
+	return kbt.kb.scan(kbt.tx, []byte(guildID.String()), []byte(collection), []byte(prefix), limit, fn)
+}
+
+func (kbt *komainuBoltTx) Increment(guildID discord.GuildID, collection string, key any, delta int64) (count int64, err error) {
+	return increment(kbt, guildID, collection, key, delta)
+}
+
+// Batch runs fn in a single bolt Update transaction, which is rolled back if fn returns an error.
+// Subscribers hear about the changes once it's committed.
+func (kb *komainuBolt) Batch(fn func(tx Tx) error) error {
+	changes, err := kb.update(fn)
+	if err == nil {
+		kb.notify(changes)
+	}
+	return err
+}
+
+// update runs fn in a single bolt Update transaction, returning the changes it made.
+func (kb *komainuBolt) update(fn func(tx Tx) error) (changes []ChangeEvent, err error) {
+	kb.swap.RLock()
+	defer kb.swap.RUnlock()
+	err = kb.bolt.Update(func(tx *bolt.Tx) error {
+		if tx == nil {
+			return errors.New("storage failed to open Update transaction")
 		}
-		bucket.ForEach(func(k, _ []byte) error {
-			keys = append(keys, string(k))
-			return nil
-		})
-		return
+		kbt := &komainuBoltTx{kb: kb, tx: tx}
+		if err := fn(kbt); err != nil {
+			return err
+		}
+		changes = kbt.changes
+		return nil
 	})
 	return
 }
 
-func (kb *komainuBolt) key(raw any) []byte {
-	return []byte(fmt.Sprintf("%v", raw))
+// view runs fn in a single read-only bolt transaction.
+func (kb *komainuBolt) view(fn func(tx Tx) error) error {
+	kb.swap.RLock()
+	defer kb.swap.RUnlock()
+	return kb.bolt.View(func(tx *bolt.Tx) error {
+		if tx == nil {
+			return errors.New("storage failed to open View transaction")
+		}
+		return fn(&komainuBoltTx{kb: kb, tx: tx})
+	})
 }
 
 func (kb *komainuBolt) Set(guildID discord.GuildID, collection string, key any, value any) (err error) {
-	guildb := []byte(guildID.String())
-	collectionb := []byte(collection)
-	keyb := kb.key(key)
-	var inputBuffer bytes.Buffer
-	if err := gob.NewEncoder(&inputBuffer).Encode(value); err != nil {
-		return fmt.Errorf("unable to encode raw value %v as gob for Set: %w", value, err)
-	}
-	return kb.store(guildb, collectionb, keyb, inputBuffer.Bytes())
+	return kb.Batch(func(tx Tx) error {
+		return tx.Set(guildID, collection, key, value)
+	})
+}
+
+func (kb *komainuBolt) SetWithTTL(guildID discord.GuildID, collection string, key any, value any, ttl time.Duration) (err error) {
+	return kb.Batch(func(tx Tx) error {
+		return tx.SetWithTTL(guildID, collection, key, value, ttl)
+	})
+}
+
+func (kb *komainuBolt) Increment(guildID discord.GuildID, collection string, key any, delta int64) (count int64, err error) {
+	return incrementInBatch(kb, guildID, collection, key, delta)
 }
 
 func (kb *komainuBolt) Get(guildID discord.GuildID, collection string, key any, out any) (found bool, err error) {
-	guildb := []byte(guildID.String())
-	collectionb := []byte(collection)
-	keyb := kb.key(key)
-	found, raw, err := kb.retrieve(guildb, collectionb, keyb)
-	if err != nil || !found {
+	err = kb.view(func(tx Tx) (err error) {
+		found, err = tx.Get(guildID, collection, key, out)
 		return
-	}
-	var outputBuffer bytes.Buffer
-	outputBuffer.Write(raw)
-	err = gob.NewDecoder(&outputBuffer).Decode(out)
+	})
 	return
 }
 
 func (kb *komainuBolt) Delete(guildID discord.GuildID, collection string, key any) (err error) {
-	guildb := []byte(guildID.String())
-	collectionb := []byte(collection)
-	keyb := kb.key(key)
-	return kb.remove(guildb, collectionb, keyb)
+	return kb.Batch(func(tx Tx) error {
+		return tx.Delete(guildID, collection, key)
+	})
 }
 
 func (kb *komainuBolt) Keys(guildID discord.GuildID, collection string) (keys []string, err error) {
-	guildb := []byte(guildID.String())
-	collectionb := []byte(collection)
-	return kb.keys(guildb, collectionb)
+	err = kb.view(func(tx Tx) (err error) {
+		keys, err = tx.Keys(guildID, collection)
+		return
+	})
+	return
+}
+
+func (kb *komainuBolt) ForEach(guildID discord.GuildID, collection string, fn ForEachFunc) (err error) {
+	return kb.view(func(tx Tx) error {
+		return tx.ForEach(guildID, collection, fn)
+	})
+}
+
+func (kb *komainuBolt) Scan(guildID discord.GuildID, collection string, prefix string, limit int, fn ForEachFunc) (err error) {
+	return kb.view(func(tx Tx) error {
+		return tx.Scan(guildID, collection, prefix, limit, fn)
+	})
+}
+
+func (kb *komainuBolt) Expired(now time.Time) (expired []ExpiredKey, err error) {
+	kb.swap.RLock()
+	defer kb.swap.RUnlock()
+	err = kb.bolt.View(func(tx *bolt.Tx) error {
+		if tx == nil {
+			return errors.New("storage failed to open View transaction")
+		}
+		return tx.ForEach(func(guild []byte, guildBucket *bolt.Bucket) error {
+			bucket := guildBucket.Bucket([]byte(expiryCollection))
+			if bucket == nil {
+				return nil
+			}
+			snowflake, err := discord.ParseSnowflake(string(guild))
+			if err != nil {
+				return nil // Not a guild, so nothing we put an expiry on.
+			}
+			return bucket.ForEach(func(k, v []byte) error {
+				var expires int64
+				if err := decodeValue(v, &expires); err != nil {
+					return fmt.Errorf("storage failed to decode expiry for %s: %w", k, err)
+				}
+				if expires > now.Unix() {
+					return nil
+				}
+				if key, ok := parseExpiryKey(discord.GuildID(snowflake), string(k)); ok {
+					expired = append(expired, key)
+				}
+				return nil
+			})
+		})
+	})
+	return
+}
+
+func (kb *komainuBolt) PurgeGuild(guildID discord.GuildID) error {
+	if err := kb.purgeGuild(guildID); err != nil {
+		return err
+	}
+	kb.notify([]ChangeEvent{{GuildID: guildID, Deleted: true}})
+	return nil
+}
+
+func (kb *komainuBolt) purgeGuild(guildID discord.GuildID) error {
+	kb.swap.RLock()
+	defer kb.swap.RUnlock()
+	return kb.bolt.Update(func(tx *bolt.Tx) error {
+		if tx == nil {
+			return errors.New("storage failed to open Update transaction")
+		}
+		err := tx.DeleteBucket([]byte(guildID.String()))
+		if err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return fmt.Errorf("bolt store failed to delete guild bucket: %w", err)
+		}
+		return nil
+	})
 }
 
 func (kb *komainuBolt) Close() error {
+	kb.swap.Lock()
+	defer kb.swap.Unlock()
 	return kb.bolt.Close()
 }
@@ -11,6 +11,38 @@ func GetKVS(path string) (KeyValueStore, error) {
 	return OpenKomainuBolt(path)
 }
 
+// backends holds every KeyValueStore, so the basic tests can make sure they all behave the same.
+var backends = map[string]func(path string) (KeyValueStore, error){
+	"bolt": GetKVS,
+	"sqlite": func(path string) (KeyValueStore, error) {
+		return OpenKomainuSQLite(path)
+	},
+	"memory": func(string) (KeyValueStore, error) {
+		return OpenMemory(), nil
+	},
+}
+
+// forEachBackend runs the test once for each of the backends, with a fresh store every time.
+func forEachBackend(t *testing.T, test func(t *testing.T, kvs KeyValueStore)) {
+	for name, open := range backends {
+		open := open
+		t.Run(name, func(t *testing.T) {
+			kvs, err := open(filename)
+			if err != nil {
+				t.Errorf("Could not open test file: %s", err)
+				return
+			}
+			t.Cleanup(func() {
+				kvs.Close()
+				os.Remove(filename)
+				os.Remove(filename + "-wal")
+				os.Remove(filename + "-shm")
+			})
+			test(t, kvs)
+		})
+	}
+}
+
 const filename = "test_storage_file"
 const col = "test collection"
 
@@ -23,128 +55,100 @@ var testGuild = discord.GuildID(211575243083350016)
 */
 
 func TestStorageInt(t *testing.T) {
-	kvs, err := GetKVS(filename)
-	if err != nil {
-		t.Errorf("Could not open test file: %s", err)
-	}
-	t.Cleanup(func() {
-		kvs.Close()
-		os.Remove(filename)
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		key := "some arbituary key"
+		input := 12345
+		if err := kvs.Set(testGuild, col, key, input); err != nil {
+			t.Errorf("Could not set test input value: %v", err)
+			return
+		}
+
+		var output int
+		found, err := kvs.Get(testGuild, col, key, &output)
+		if err != nil {
+			t.Errorf("Could not retrieve value: %v", err)
+			return
+		}
+		if !found {
+			t.Error("Value was not found when trying to read it back!")
+			return
+		}
+		if input != output {
+			t.Errorf("Expected %d, Got %d", input, output)
+			return
+		}
 	})
-
-	key := "some arbituary key"
-	input := 12345
-	if err := kvs.Set(testGuild, col, key, input); err != nil {
-		t.Errorf("Could not set test input value: %v", err)
-		return
-	}
-
-	var output int
-	found, err := kvs.Get(testGuild, col, key, &output)
-	if err != nil {
-		t.Errorf("Could not retrieve value: %v", err)
-		return
-	}
-	if !found {
-		t.Error("Value was not found when trying to read it back!")
-		return
-	}
-	if input != output {
-		t.Errorf("Expected %d, Got %d", input, output)
-		return
-	}
 }
 
 func TestStorageString(t *testing.T) {
-	kvs, err := GetKVS(filename)
-	if err != nil {
-		t.Errorf("Could not open test file: %s", err)
-	}
-	t.Cleanup(func() {
-		kvs.Close()
-		os.Remove(filename)
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		key := "some arbituary key"
+		input := "some arbituary string"
+		if err := kvs.Set(testGuild, col, key, input); err != nil {
+			t.Errorf("Could not set test input value: %v", err)
+			return
+		}
+
+		var output string
+		found, err := kvs.Get(testGuild, col, key, &output)
+		if err != nil {
+			t.Errorf("Could not retrieve value: %v", err)
+			return
+		}
+		if !found {
+			t.Error("Value was not found when trying to read it back!")
+			return
+		}
+		if input != output {
+			t.Errorf("Expected %s, Got %s", input, output)
+			return
+		}
 	})
-
-	key := "some arbituary key"
-	input := "some arbituary string"
-	if err := kvs.Set(testGuild, col, key, input); err != nil {
-		t.Errorf("Could not set test input value: %v", err)
-		return
-	}
-
-	var output string
-	found, err := kvs.Get(testGuild, col, key, &output)
-	if err != nil {
-		t.Errorf("Could not retrieve value: %v", err)
-		return
-	}
-	if !found {
-		t.Error("Value was not found when trying to read it back!")
-		return
-	}
-	if input != output {
-		t.Errorf("Expected %s, Got %s", input, output)
-		return
-	}
 }
 
 func TestStorageObject(t *testing.T) {
-	kvs, err := GetKVS(filename)
-	if err != nil {
-		t.Errorf("Could not open test file: %s", err)
-	}
-	t.Cleanup(func() {
-		kvs.Close()
-		os.Remove(filename)
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		type SomeTestStruct struct {
+			First  string
+			Second int
+		}
+
+		key := "some arbituary key"
+		input := SomeTestStruct{"foo", 123}
+		if err := kvs.Set(testGuild, col, key, input); err != nil {
+			t.Errorf("Could not set test input value: %v", err)
+			return
+		}
+
+		var output SomeTestStruct
+		found, err := kvs.Get(testGuild, col, key, &output)
+		if err != nil {
+			t.Errorf("Could not retrieve value: %v", err)
+		}
+		if !found {
+			t.Error("Value was not found when trying to read it back!")
+		}
+		if input.First != output.First {
+			t.Errorf("First field: Expected %s, Got %s", input.First, output.First)
+		}
+		if input.Second != output.Second {
+			t.Errorf("Second field: Expected %d, Got %d", input.Second, output.Second)
+		}
 	})
-
-	type SomeTestStruct struct {
-		First  string
-		Second int
-	}
-
-	key := "some arbituary key"
-	input := SomeTestStruct{"foo", 123}
-	if err := kvs.Set(testGuild, col, key, input); err != nil {
-		t.Errorf("Could not set test input value: %v", err)
-		return
-	}
-
-	var output SomeTestStruct
-	found, err := kvs.Get(testGuild, col, key, &output)
-	if err != nil {
-		t.Errorf("Could not retrieve value: %v", err)
-	}
-	if !found {
-		t.Error("Value was not found when trying to read it back!")
-	}
-	if input.First != output.First {
-		t.Errorf("First field: Expected %s, Got %s", input.First, output.First)
-	}
-	if input.Second != output.Second {
-		t.Errorf("Second field: Expected %d, Got %d", input.Second, output.Second)
-	}
 }
 
 func TestMissingValue(t *testing.T) {
-	kvs, err := GetKVS(filename)
-	if err != nil {
-		t.Errorf("Could not open test file: %s", err)
-	}
-	t.Cleanup(func() {
-		kvs.Close()
-		os.Remove(filename)
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		var output string
+		key := "some arbituary key"
+		found, err := kvs.Get(testGuild, col, key, &output)
+		if err != nil {
+			t.Errorf("Error getting value: %v", err)
+			return
+		}
+		if found {
+			t.Errorf("Unexpectedly got a value: %v", output)
+			return
+		}
 	})
-
-	var output string
-	key := "some arbituary key"
-	found, err := kvs.Get(testGuild, col, key, &output)
-	if err != nil {
-		t.Errorf("Error getting value: %v", err)
-		return
-	}
-	if found {
-		t.Errorf("Unexpectedly got a value: %v", output)
-		return
-	}
 }
@@ -1,13 +1,44 @@
 package storage
 
 import (
+	"time"
+
 	"github.com/diamondburned/arikawa/v3/discord"
 )
 
-type KeyValueStore interface {
-	Close() error
+// ValueDecoder decodes the value it was handed out with into the given reference.
+// It is only valid until the function it was given to returns.
+type ValueDecoder func(out any) error
+
+// ForEachFunc is called with each key, and a way to decode its value. Returning an error stops the iteration.
+// Don't change the collection being iterated from inside it.
+type ForEachFunc func(key string, value ValueDecoder) error
+
+// Tx holds the operations that can be done both directly on a KeyValueStore, and grouped together in a Batch.
+type Tx interface {
 	Set(guild discord.GuildID, collection string, key any, rawValue any) (err error)
+	SetWithTTL(guild discord.GuildID, collection string, key any, rawValue any, ttl time.Duration) (err error)
 	Get(guild discord.GuildID, collection string, key any, out any) (exist bool, err error)
 	Delete(guild discord.GuildID, collection string, key any) (err error)
 	Keys(guild discord.GuildID, collection string) (keys []string, err error)
+	// ForEach calls fn for every key in the collection, in key order.
+	ForEach(guild discord.GuildID, collection string, fn ForEachFunc) (err error)
+	// Scan calls fn for at most limit keys starting with prefix, in key order. A limit of zero or less means no limit.
+	Scan(guild discord.GuildID, collection string, prefix string, limit int, fn ForEachFunc) (err error)
+	// Increment adds delta to the int64 counter under key, starting from zero, and returns the new count.
+	// Nobody else can change the counter in between reading and writing it.
+	Increment(guild discord.GuildID, collection string, key any, delta int64) (count int64, err error)
+}
+
+type KeyValueStore interface {
+	Tx
+	Close() error
+	Expired(now time.Time) (expired []ExpiredKey, err error)
+	// Batch runs fn with a Tx where either all the changes happen, or none of them do if fn returns an error.
+	// Only use the given Tx inside fn, not the KeyValueStore itself.
+	Batch(fn func(tx Tx) error) error
+	// PurgeGuild removes everything stored for the given guild.
+	PurgeGuild(guild discord.GuildID) error
+	// Subscribe calls fn for each change to the given collection, once it's committed. See watchers.Subscribe.
+	Subscribe(guild discord.GuildID, collection string, fn ChangeFunc) (unsubscribe func())
 }
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	bolt "go.etcd.io/bbolt"
+)
+
+// LegacyCollectionReport counts what happened to the keys of one collection during a legacy migration.
+type LegacyCollectionReport struct {
+	Migrated int
+	Verified int
+	Failed   int
+}
+
+// LegacyMigrationReport describes the outcome of MigrateLegacyBolt.
+type LegacyMigrationReport struct {
+	Guilds      map[discord.GuildID]bool
+	Collections map[string]*LegacyCollectionReport
+	Skipped     []string
+	Failures    []string
+}
+
+// OK checks if every key was migrated and verified, and nothing had to be skipped.
+func (report *LegacyMigrationReport) OK() bool {
+	return len(report.Skipped) == 0 && len(report.Failures) == 0
+}
+
+// String formats the report as a table suitable for a terminal.
+func (report *LegacyMigrationReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Guilds migrated: %d\n\n", len(report.Guilds))
+
+	names := make([]string, 0, len(report.Collections))
+	for name := range report.Collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	table := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "Collection\tMigrated\tVerified\tFailed")
+	for _, name := range names {
+		counts := report.Collections[name]
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\n", name, counts.Migrated, counts.Verified, counts.Failed)
+	}
+	table.Flush()
+
+	if len(report.Skipped) > 0 {
+		fmt.Fprintln(&sb, "\nSkipped buckets:")
+		for _, skipped := range report.Skipped {
+			fmt.Fprintf(&sb, "- %s\n", skipped)
+		}
+	}
+	if len(report.Failures) > 0 {
+		fmt.Fprintln(&sb, "\nFailures:")
+		for _, failure := range report.Failures {
+			fmt.Fprintf(&sb, "- %s\n", failure)
+		}
+	}
+	return sb.String()
+}
+
+// splitBucketName turns a legacy "guild/collection" bucket name back into its parts.
+func (b *boltData) splitBucketName(name []byte) (guild discord.GuildID, collection string, ok bool) {
+	parts := strings.SplitN(string(name), "/", 2)
+	if len(parts) != 2 {
+		return discord.NullGuildID, "", false
+	}
+	snowflake, err := discord.ParseSnowflake(parts[0])
+	if err != nil {
+		return discord.NullGuildID, "", false
+	}
+	return discord.GuildID(snowflake), parts[1], true
+}
+
+// decodeLegacyValue turns a raw value written by boltData.Set into a reference to the type stored in the given collection.
+// This mirrors the type switch in boltData.Set: A few basic types were stored raw, everything else was gob.
+func decodeLegacyValue(collection string, key string, raw []byte) (value any, err error) {
+	value, known := newValueFor(collection, key)
+	if !known {
+		return nil, fmt.Errorf("unknown collection %q", collection)
+	}
+	switch target := value.(type) {
+	case *string:
+		*target = string(raw)
+	case *int64:
+		if len(raw) != 8 {
+			return nil, fmt.Errorf("expected 8 bytes for an int64, got %d", len(raw))
+		}
+		*target = int64(binary.LittleEndian.Uint64(raw))
+	case *float64:
+		if len(raw) != 8 {
+			return nil, fmt.Errorf("expected 8 bytes for a float64, got %d", len(raw))
+		}
+		*target = math.Float64frombits(binary.LittleEndian.Uint64(raw))
+	default:
+		err = gobCodec{}.Decode(raw, value)
+	}
+	return value, err
+}
+
+// MigrateLegacyBolt copies everything from a database written by boltData into the given KeyValueStore,
+// converting each value to the current encoding and reading it back to verify it.
+func MigrateLegacyBolt(legacyPath string, kvs KeyValueStore) (report *LegacyMigrationReport, err error) {
+	report = &LegacyMigrationReport{
+		Guilds:      map[discord.GuildID]bool{},
+		Collections: map[string]*LegacyCollectionReport{},
+	}
+
+	if _, err := os.Stat(legacyPath); err != nil {
+		return report, fmt.Errorf("could not find legacy database: %w", err) // OpenBolt would happily create an empty one.
+	}
+	legacy, err := OpenBolt(legacyPath)
+	if err != nil {
+		return report, fmt.Errorf("could not open legacy database: %w", err)
+	}
+	defer legacy.Close()
+
+	err = legacy.bolt.View(func(tx *bolt.Tx) error {
+		if tx == nil {
+			return errors.New("could not migrate from bolt: failed to open transaction")
+		}
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			guildID, collection, ok := legacy.splitBucketName(name)
+			if !ok {
+				report.Skipped = append(report.Skipped, fmt.Sprintf("%s (not a guild/collection bucket)", name))
+				return nil
+			}
+			if _, known := collectionTypes[collection]; !known {
+				report.Skipped = append(report.Skipped, fmt.Sprintf("%s (unknown collection)", name))
+				return nil
+			}
+			report.Guilds[guildID] = true
+			counts, ok := report.Collections[collection]
+			if !ok {
+				counts = &LegacyCollectionReport{}
+				report.Collections[collection] = counts
+			}
+			return bucket.ForEach(func(k, v []byte) error {
+				key := string(k)
+				stored, err := migrateLegacyValue(kvs, guildID, collection, key, v)
+				if stored {
+					counts.Migrated++
+				}
+				if err != nil {
+					counts.Failed++
+					report.Failures = append(report.Failures, fmt.Sprintf("%s/%s/%s: %s", guildID, collection, key, err))
+					return nil
+				}
+				counts.Verified++
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return report, err
+	}
+	// The guilds were stamped with the current schema version when first written to, so the settings have to be moved over by hand.
+	for guildID := range report.Guilds {
+		if err := migrateGuildSettings(kvs, guildID); err != nil {
+			return report, fmt.Errorf("could not move the settings of %s: %w", guildID, err)
+		}
+	}
+	return report, nil
+}
+
+// migrateLegacyValue converts, stores and verifies a single legacy value.
+func migrateLegacyValue(kvs KeyValueStore, guildID discord.GuildID, collection string, key string, raw []byte) (stored bool, err error) {
+	value, err := decodeLegacyValue(collection, key, raw)
+	if err != nil {
+		return false, fmt.Errorf("decoding: %w", err)
+	}
+	if err := storeDecoded(kvs, guildID, collection, key, value); err != nil {
+		return false, fmt.Errorf("storing: %w", err)
+	}
+	readBack, _ := newValueFor(collection, key)
+	found, err := kvs.Get(guildID, collection, key, readBack)
+	if err != nil {
+		return true, fmt.Errorf("verifying: %w", err)
+	}
+	if !found {
+		return true, errors.New("verifying: value was not found after storing it")
+	}
+	if !reflect.DeepEqual(value, readBack) {
+		return true, errors.New("verifying: value read back does not match")
+	}
+	return true, nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+const legacyFilename = "test_legacy_file"
+
+func TestMigrateLegacyBolt(t *testing.T) {
+	legacy, err := OpenBolt(legacyFilename)
+	if err != nil {
+		t.Errorf("Could not open legacy test file: %s", err)
+		return
+	}
+	t.Cleanup(func() {
+		os.Remove(legacyFilename)
+	})
+
+	userID := discord.UserID(42)
+	roleID := discord.RoleID(1234)
+	writes := []struct {
+		collection string
+		key        any
+		value      any
+	}{
+		{"seen", userID, int64(1660000000)},
+		{"faq", "horseradish", "It's a root."},
+		{"activerole", "days", 30.5},
+		{"activerole", "role", roleID},
+		{"mystery", "what", "is this?"},
+	}
+	for _, write := range writes {
+		if err := legacy.Set(testGuild, write.collection, write.key, write.value); err != nil {
+			t.Errorf("Could not write legacy value: %s", err)
+			return
+		}
+	}
+	legacy.Close()
+
+	kvs := OpenMemory()
+	report, err := MigrateLegacyBolt(legacyFilename, kvs)
+	if err != nil {
+		t.Errorf("Migration failed: %s", err)
+		return
+	}
+	if len(report.Failures) != 0 {
+		t.Errorf("Unexpected failures: %v", report.Failures)
+	}
+	if len(report.Skipped) != 1 {
+		t.Errorf("Expected the mystery bucket to be skipped, Got %v", report.Skipped)
+	}
+	if report.Collections["activerole"].Verified != 2 {
+		t.Errorf("Expected 2 verified activerole keys, Got %d", report.Collections["activerole"].Verified)
+	}
+
+	exist, when, err := LastSeen(kvs, testGuild, userID)
+	if err != nil || !exist || when != 1660000000 {
+		t.Errorf("Expected seen at 1660000000, Got %d (exist: %t, err: %v)", when, exist, err)
+	}
+	var faq string
+	if _, err := kvs.Get(testGuild, "faq", "horseradish", &faq); err != nil || faq != "It's a root." {
+		t.Errorf("Expected the FAQ to survive, Got %q (%v)", faq, err)
+	}
+	if role, isSet, err := activeRole.Get(kvs, testGuild); err != nil || !isSet || role != roleID {
+		t.Errorf("Expected the role to become the activerole.role setting, Got %s (set: %t, %v)", role, isSet, err)
+	}
+	if after, isSet, err := activeRoleAfter.Get(kvs, testGuild); err != nil || !isSet || after != 732*time.Hour {
+		t.Errorf("Expected 30.5 days to become the activerole.after setting, Got %s (set: %t, %v)", after, isSet, err)
+	}
+}
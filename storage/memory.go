@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// memoryStore is a KeyValueStore that only lives in memory.
+// Values are encoded just like komainuBolt does it, so anything that round-trips here also round-trips there.
+type memoryStore struct {
+	mutex  sync.RWMutex
+	guilds map[discord.GuildID]map[string]map[string][]byte
+	watchers
+}
+
+// OpenMemory creates a fresh, empty KeyValueStore that is lost when the program exits.
+// Handy for tests and throwaway dev sessions.
+func OpenMemory() *memoryStore {
+	return &memoryStore{
+		guilds: map[discord.GuildID]map[string]map[string][]byte{},
+	}
+}
+
+// bucket returns the map for the given guild and collection, creating it if asked to.
+// The caller must hold the appropriate lock.
+func (ms *memoryStore) bucket(guildID discord.GuildID, collection string, create bool) map[string][]byte {
+	guild, ok := ms.guilds[guildID]
+	if !ok {
+		if !create {
+			return nil
+		}
+		guild = map[string]map[string][]byte{}
+		if version, err := encodeValue(CurrentSchemaVersion()); err == nil {
+			guild[schemaCollection] = map[string][]byte{schemaVersionKey: version} // New guilds are always current, just like in komainuBolt.
+		}
+		ms.guilds[guildID] = guild
+	}
+	bucket, ok := guild[collection]
+	if !ok {
+		if !create {
+			return nil
+		}
+		bucket = map[string][]byte{}
+		guild[collection] = bucket
+	}
+	return bucket
+}
+
+// memoryTx is a Tx on a memoryStore. The store's lock is held for as long as it is in use.
+// Changes are made right away, but each one remembers how to undo itself in case the batch fails.
+type memoryTx struct {
+	ms       *memoryStore
+	writable bool
+	undo     []func()
+	changes  []ChangeEvent
+}
+
+// put stores a raw value, remembering what was there before.
+func (mt *memoryTx) put(guildID discord.GuildID, collection string, key string, raw []byte) error {
+	if !mt.writable {
+		return errors.New("storage can't write in a read-only transaction")
+	}
+	mt.remember(guildID, collection, key)
+	mt.ms.bucket(guildID, collection, true)[key] = raw
+	return nil
+}
+
+// remove deletes a raw value, remembering what was there before.
+func (mt *memoryTx) remove(guildID discord.GuildID, collection string, key string) error {
+	if !mt.writable {
+		return errors.New("storage can't write in a read-only transaction")
+	}
+	mt.remember(guildID, collection, key)
+	delete(mt.ms.bucket(guildID, collection, false), key)
+	return nil
+}
+
+// remember adds an undo step that puts the given key back the way it is right now.
+func (mt *memoryTx) remember(guildID discord.GuildID, collection string, key string) {
+	if _, guildExisted := mt.ms.guilds[guildID]; !guildExisted {
+		mt.undo = append(mt.undo, func() {
+			delete(mt.ms.guilds, guildID)
+		})
+		return
+	}
+	old, existed := mt.ms.bucket(guildID, collection, false)[key]
+	mt.undo = append(mt.undo, func() {
+		if existed {
+			mt.ms.bucket(guildID, collection, true)[key] = old
+		} else {
+			delete(mt.ms.bucket(guildID, collection, false), key)
+		}
+	})
+}
+
+// rollback undoes every change made in the transaction, newest first.
+func (mt *memoryTx) rollback() {
+	for i := len(mt.undo) - 1; i >= 0; i-- {
+		mt.undo[i]()
+	}
+	mt.undo = nil
+}
+
+// store puts the value in memory. If expires is non-zero, the key is due to expire at that unix time, otherwise any expiry is cleared.
+func (mt *memoryTx) store(guildID discord.GuildID, collection string, key any, value any, expires int64) (err error) {
+	encoded, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+	if err := mt.put(guildID, collection, keyString(key), encoded); err != nil {
+		return err
+	}
+	return mt.setExpiry(guildID, collection, keyString(key), expires)
+}
+
+// setExpiry records when the given key expires, or forgets about it if expires is zero.
+func (mt *memoryTx) setExpiry(guildID discord.GuildID, collection string, key string, expires int64) error {
+	if expires == 0 {
+		if _, exist := mt.ms.bucket(guildID, expiryCollection, false)[expiryKey(collection, key)]; !exist {
+			return nil
+		}
+		return mt.remove(guildID, expiryCollection, expiryKey(collection, key))
+	}
+	encoded, err := encodeValue(expires)
+	if err != nil {
+		return err
+	}
+	return mt.put(guildID, expiryCollection, expiryKey(collection, key), encoded)
+}
+
+func (mt *memoryTx) Set(guildID discord.GuildID, collection string, key any, value any) (err error) {
+	if err := mt.store(guildID, collection, key, value, 0); err != nil {
+		return err
+	}
+	mt.changes = append(mt.changes, ChangeEvent{guildID, collection, keyString(key), false})
+	return nil
+}
+
+func (mt *memoryTx) SetWithTTL(guildID discord.GuildID, collection string, key any, value any, ttl time.Duration) (err error) {
+	if err := mt.store(guildID, collection, key, value, expiryTime(ttl)); err != nil {
+		return err
+	}
+	mt.changes = append(mt.changes, ChangeEvent{guildID, collection, keyString(key), false})
+	return nil
+}
+
+func (mt *memoryTx) Get(guildID discord.GuildID, collection string, key any, out any) (found bool, err error) {
+	raw, found := mt.ms.bucket(guildID, collection, false)[keyString(key)]
+	if !found {
+		return
+	}
+	err = decodeValue(raw, out)
+	return
+}
+
+func (mt *memoryTx) Delete(guildID discord.GuildID, collection string, key any) (err error) {
+	if err := mt.remove(guildID, collection, keyString(key)); err != nil {
+		return err
+	}
+	if err := mt.setExpiry(guildID, collection, keyString(key), 0); err != nil {
+		return err
+	}
+	mt.changes = append(mt.changes, ChangeEvent{guildID, collection, keyString(key), true})
+	return nil
+}
+
+func (mt *memoryTx) Keys(guildID discord.GuildID, collection string) (keys []string, err error) {
+	for key := range mt.ms.bucket(guildID, collection, false) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // bolt hands them out in byte order, so we do too.
+	return
+}
+
+func (mt *memoryTx) ForEach(guildID discord.GuildID, collection string, fn ForEachFunc) (err error) {
+	return mt.Scan(guildID, collection, "", 0, fn)
+}
+
+func (mt *memoryTx) Scan(guildID discord.GuildID, collection string, prefix string, limit int, fn ForEachFunc) (err error) {
+	keys, _ := mt.Keys(guildID, collection)
+	bucket := mt.ms.bucket(guildID, collection, false)
+	count := 0
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if limit > 0 && count >= limit {
+			break
+		}
+		value := bucket[key]
+		if err := fn(key, func(out any) error { return decodeValue(value, out) }); err != nil {
+			return err
+		}
+		count++
+	}
+	return nil
+}
+
+func (mt *memoryTx) Increment(guildID discord.GuildID, collection string, key any, delta int64) (count int64, err error) {
+	return increment(mt, guildID, collection, key, delta)
+}
+
+// Batch runs fn while holding the write lock, undoing all of its changes if it returns an error.
+// Subscribers hear about the changes once the lock is released.
+func (ms *memoryStore) Batch(fn func(tx Tx) error) error {
+	changes, err := ms.update(fn)
+	if err == nil {
+		ms.notify(changes)
+	}
+	return err
+}
+
+// update runs fn while holding the write lock, returning the changes it made.
+func (ms *memoryStore) update(fn func(tx Tx) error) ([]ChangeEvent, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	tx := &memoryTx{ms: ms, writable: true}
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return nil, err
+	}
+	return tx.changes, nil
+}
+
+// view runs fn while holding the read lock.
+func (ms *memoryStore) view(fn func(tx Tx) error) error {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	return fn(&memoryTx{ms: ms})
+}
+
+func (ms *memoryStore) Set(guildID discord.GuildID, collection string, key any, value any) (err error) {
+	return ms.Batch(func(tx Tx) error {
+		return tx.Set(guildID, collection, key, value)
+	})
+}
+
+func (ms *memoryStore) SetWithTTL(guildID discord.GuildID, collection string, key any, value any, ttl time.Duration) (err error) {
+	return ms.Batch(func(tx Tx) error {
+		return tx.SetWithTTL(guildID, collection, key, value, ttl)
+	})
+}
+
+func (ms *memoryStore) Increment(guildID discord.GuildID, collection string, key any, delta int64) (count int64, err error) {
+	return incrementInBatch(ms, guildID, collection, key, delta)
+}
+
+func (ms *memoryStore) Get(guildID discord.GuildID, collection string, key any, out any) (found bool, err error) {
+	err = ms.view(func(tx Tx) (err error) {
+		found, err = tx.Get(guildID, collection, key, out)
+		return
+	})
+	return
+}
+
+func (ms *memoryStore) Delete(guildID discord.GuildID, collection string, key any) (err error) {
+	return ms.Batch(func(tx Tx) error {
+		return tx.Delete(guildID, collection, key)
+	})
+}
+
+func (ms *memoryStore) Keys(guildID discord.GuildID, collection string) (keys []string, err error) {
+	err = ms.view(func(tx Tx) (err error) {
+		keys, err = tx.Keys(guildID, collection)
+		return
+	})
+	return
+}
+
+func (ms *memoryStore) ForEach(guildID discord.GuildID, collection string, fn ForEachFunc) (err error) {
+	return ms.view(func(tx Tx) error {
+		return tx.ForEach(guildID, collection, fn)
+	})
+}
+
+func (ms *memoryStore) Scan(guildID discord.GuildID, collection string, prefix string, limit int, fn ForEachFunc) (err error) {
+	return ms.view(func(tx Tx) error {
+		return tx.Scan(guildID, collection, prefix, limit, fn)
+	})
+}
+
+func (ms *memoryStore) Expired(now time.Time) (expired []ExpiredKey, err error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	for guildID, guild := range ms.guilds {
+		for rawKey, raw := range guild[expiryCollection] {
+			var expires int64
+			if err := decodeValue(raw, &expires); err != nil {
+				return nil, fmt.Errorf("storage failed to decode expiry for %s: %w", rawKey, err)
+			}
+			if expires > now.Unix() {
+				continue
+			}
+			if key, ok := parseExpiryKey(guildID, rawKey); ok {
+				expired = append(expired, key)
+			}
+		}
+	}
+	return
+}
+
+func (ms *memoryStore) PurgeGuild(guildID discord.GuildID) error {
+	ms.mutex.Lock()
+	delete(ms.guilds, guildID)
+	ms.mutex.Unlock()
+	ms.notify([]ChangeEvent{{GuildID: guildID, Deleted: true}})
+	return nil
+}
+
+func (ms *memoryStore) Close() error {
+	return nil
+}
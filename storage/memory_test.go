@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMemoryMatchesBolt(t *testing.T) {
+	kvs, err := GetKVS(filename)
+	if err != nil {
+		t.Errorf("Could not open test file: %s", err)
+	}
+	t.Cleanup(func() {
+		kvs.Close()
+		os.Remove(filename)
+	})
+	mem := OpenMemory()
+
+	type SomeTestStruct struct {
+		First  string
+		Second int
+	}
+
+	key := "some arbituary key"
+	input := SomeTestStruct{"foo", 123}
+	for _, store := range []KeyValueStore{kvs, mem} {
+		if err := store.Set(testGuild, col, key, input); err != nil {
+			t.Errorf("Could not set test input value: %v", err)
+			return
+		}
+	}
+
+	boltRaw := kvs.(*komainuBolt)
+	var fromBolt []byte
+	var found bool
+	err = boltRaw.bolt.View(func(tx *bolt.Tx) error {
+		var raw []byte
+		found, raw = boltRaw.retrieve(tx, []byte(testGuild.String()), []byte(col), []byte(key))
+		fromBolt = append(fromBolt, raw...)
+		return nil
+	})
+	if err != nil || !found {
+		t.Errorf("Could not read raw value back from bolt: %v", err)
+		return
+	}
+	fromMemory := mem.guilds[testGuild][col][key]
+	if string(fromBolt) != string(fromMemory) {
+		t.Errorf("Encoded values differ between bolt and memory:\n%v\n%v", fromBolt, fromMemory)
+	}
+
+	var output SomeTestStruct
+	found, err = mem.Get(testGuild, col, key, &output)
+	if err != nil {
+		t.Errorf("Could not retrieve value: %v", err)
+	}
+	if !found {
+		t.Error("Value was not found when trying to read it back!")
+	}
+	if input != output {
+		t.Errorf("Expected %v, Got %v", input, output)
+	}
+}
+
+func TestMemoryKeysAndDelete(t *testing.T) {
+	mem := OpenMemory()
+	for _, key := range []string{"charlie", "alpha", "bravo"} {
+		if err := mem.Set(testGuild, col, key, key); err != nil {
+			t.Errorf("Could not set test input value: %v", err)
+			return
+		}
+	}
+	if err := mem.Delete(testGuild, col, "bravo"); err != nil {
+		t.Errorf("Could not delete value: %v", err)
+		return
+	}
+	keys, err := mem.Keys(testGuild, col)
+	if err != nil {
+		t.Errorf("Could not list keys: %v", err)
+		return
+	}
+	if len(keys) != 2 || keys[0] != "alpha" || keys[1] != "charlie" {
+		t.Errorf("Expected [alpha charlie], Got %v", keys)
+	}
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+const (
+	purgeCollection = "purge"
+	purgeKey        = "pending"
+)
+
+func init() {
+	OnExpire(purgeCollection, purgeExpiredGuild)
+}
+
+// SchedulePurge marks the given guild for deletion once the grace period is over.
+// The mark lives in the guild itself, and expires like any other key, so the purge happens in SweepExpired.
+func SchedulePurge(kvs KeyValueStore, guildID discord.GuildID, grace time.Duration) error {
+	return kvs.SetWithTTL(guildID, purgeCollection, purgeKey, time.Now().Unix(), grace)
+}
+
+// CancelPurge removes any pending purge of the given guild. Returns whether there was one.
+func CancelPurge(kvs KeyValueStore, guildID discord.GuildID) (cancelled bool, err error) {
+	var scheduled int64
+	if cancelled, err = kvs.Get(guildID, purgeCollection, purgeKey, &scheduled); err != nil || !cancelled {
+		return // Checked first, as this happens for every guild on every connect.
+	}
+	return true, kvs.Delete(guildID, purgeCollection, purgeKey)
+}
+
+// purgeExpiredGuild is the ExpireFunc that removes everything stored for a guild once its grace period is over.
+func purgeExpiredGuild(kvs KeyValueStore, expired ExpiredKey) error {
+	if err := kvs.PurgeGuild(expired.GuildID); err != nil {
+		return fmt.Errorf("failed to purge guild data: %w", err)
+	}
+	log.Printf("[%s] Grace period is over, so all guild data was purged", expired.GuildID)
+	return nil
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+func TestPurgeGuild(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		otherGuild := testGuild + 1
+		for _, guildID := range []discord.GuildID{testGuild, otherGuild} {
+			if err := kvs.Set(guildID, col, "some arbituary key", "some arbituary string"); err != nil {
+				t.Errorf("Could not set test input value: %v", err)
+				return
+			}
+		}
+
+		if err := SchedulePurge(kvs, testGuild, time.Hour); err != nil {
+			t.Errorf("Could not schedule purge: %v", err)
+			return
+		}
+		if cancelled, err := CancelPurge(kvs, testGuild); err != nil || !cancelled {
+			t.Errorf("Expected the purge to be cancelled, Got %v (%v)", cancelled, err)
+		}
+		if err := SchedulePurge(kvs, otherGuild, -time.Second); err != nil {
+			t.Errorf("Could not schedule purge: %v", err)
+			return
+		}
+		if err := SweepExpired(kvs); err != nil {
+			t.Errorf("Sweep failed: %v", err)
+			return
+		}
+
+		if keys, _ := kvs.Keys(testGuild, col); len(keys) != 1 {
+			t.Errorf("Expected the guild with a cancelled purge to keep its data, Got %v", keys)
+		}
+		for _, collection := range []string{col, schemaCollection, expiryCollection, purgeCollection} {
+			if keys, _ := kvs.Keys(otherGuild, collection); len(keys) != 0 {
+				t.Errorf("Expected the purged guild to have nothing in %s, Got %v", collection, keys)
+			}
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		for i, key := range []string{"apple", "apricot", "avocado", "banana", "ap"} {
+			if err := kvs.Set(testGuild, col, key, i); err != nil {
+				t.Errorf("Could not set test input value: %v", err)
+				return
+			}
+		}
+
+		var found []string
+		total := 0
+		err := kvs.ForEach(testGuild, col, func(key string, value ValueDecoder) error {
+			var number int
+			if err := value(&number); err != nil {
+				return err
+			}
+			found = append(found, key)
+			total += number
+			return nil
+		})
+		if err != nil {
+			t.Errorf("ForEach failed: %v", err)
+			return
+		}
+		if strings.Join(found, ",") != "ap,apple,apricot,avocado,banana" || total != 10 {
+			t.Errorf("ForEach got %v with a total of %d", found, total)
+		}
+
+		found = nil
+		err = kvs.Scan(testGuild, col, "ap", 2, func(key string, _ ValueDecoder) error {
+			found = append(found, key)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Scan failed: %v", err)
+			return
+		}
+		if strings.Join(found, ",") != "ap,apple" {
+			t.Errorf("Expected [ap apple], Got %v", found)
+		}
+
+		found = nil
+		err = kvs.Scan(testGuild, col, "ap", 0, func(key string, _ ValueDecoder) error {
+			found = append(found, key)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Scan failed: %v", err)
+			return
+		}
+		if strings.Join(found, ",") != "ap,apple,apricot" {
+			t.Errorf("Expected [ap apple apricot], Got %v", found)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+const (
+	schemaCollection = "schema"
+	schemaVersionKey = "version"
+)
+
+// SchemaMigration upgrades everything stored for a guild to the given Version, from the version before it.
+// Migrate runs in the same Batch that records the new version, so a failed migration leaves the guild untouched.
+type SchemaMigration struct {
+	Version     int
+	Description string
+	Migrate     func(tx Tx, guildID discord.GuildID) error
+}
+
+// schemaMigrations holds the registered migrations, in no particular order.
+var schemaMigrations = []SchemaMigration{}
+
+// SchemaDryRun makes OpenKomainuBolt only log the schema migrations it would have run, without running them.
+var SchemaDryRun bool
+
+func init() {
+	RegisterSchemaMigration(SchemaMigration{
+		Version:     1,
+		Description: "Start keeping track of the schema version",
+		Migrate:     func(Tx, discord.GuildID) error { return nil },
+	})
+}
+
+// RegisterSchemaMigration adds a migration to the registry. Versions must be unique.
+func RegisterSchemaMigration(migration SchemaMigration) {
+	for _, existing := range schemaMigrations {
+		if existing.Version == migration.Version {
+			log.Fatalf("Schema migration %d registered twice: %q and %q", migration.Version, existing.Description, migration.Description)
+		}
+	}
+	schemaMigrations = append(schemaMigrations, migration)
+	sort.Slice(schemaMigrations, func(i, j int) bool {
+		return schemaMigrations[i].Version < schemaMigrations[j].Version
+	})
+}
+
+// CurrentSchemaVersion is the version of the newest registered migration, which is what new guilds start out at.
+func CurrentSchemaVersion() int {
+	if len(schemaMigrations) == 0 {
+		return 0
+	}
+	return schemaMigrations[len(schemaMigrations)-1].Version
+}
+
+// SchemaVersion returns what schema version the data for the given guild is at. Guilds without a record are at 0.
+func SchemaVersion(kvs KeyValueStore, guildID discord.GuildID) (version int, err error) {
+	_, err = kvs.Get(guildID, schemaCollection, schemaVersionKey, &version)
+	return
+}
+
+// MigrateSchema brings the given guild up to the current schema version, one migration at a time.
+// If dryRun is set, it only logs what it would have done. Returns the number of migrations run (or pending, for a dry run).
+func MigrateSchema(kvs KeyValueStore, guildID discord.GuildID, dryRun bool) (count int, err error) {
+	version, err := SchemaVersion(kvs, guildID)
+	if err != nil {
+		return 0, fmt.Errorf("could not determine schema version: %w", err)
+	}
+	for _, migration := range schemaMigrations {
+		if migration.Version <= version {
+			continue
+		}
+		if dryRun {
+			log.Printf("[%s] Would apply schema migration %d: %s (dry run)", guildID, migration.Version, migration.Description)
+			count++
+			continue
+		}
+		log.Printf("[%s] Applying schema migration %d: %s", guildID, migration.Version, migration.Description)
+		err := kvs.Batch(func(tx Tx) error {
+			if err := migration.Migrate(tx, guildID); err != nil {
+				return err
+			}
+			return tx.Set(guildID, schemaCollection, schemaVersionKey, migration.Version)
+		})
+		if err != nil {
+			return count, fmt.Errorf("schema migration %d failed, and was rolled back: %w", migration.Version, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MigrateSchemas runs MigrateSchema for each of the given guilds, stopping at the first failure.
+func MigrateSchemas(kvs KeyValueStore, guilds []discord.GuildID, dryRun bool) error {
+	total := 0
+	for _, guildID := range guilds {
+		count, err := MigrateSchema(kvs, guildID, dryRun)
+		total += count
+		if err != nil {
+			return fmt.Errorf("[%s] %w", guildID, err)
+		}
+	}
+	if total > 0 && dryRun {
+		log.Printf("%d schema migrations pending across %d guilds", total, len(guilds))
+	} else if total > 0 {
+		log.Printf("%d schema migrations applied across %d guilds", total, len(guilds))
+	}
+	return nil
+}
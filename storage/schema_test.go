@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewGuildIsCurrent(t *testing.T) {
+	kvs := OpenMemory()
+	if err := kvs.Set(testGuild, col, "some arbituary key", 1); err != nil {
+		t.Errorf("Could not set test input value: %v", err)
+		return
+	}
+	version, err := SchemaVersion(kvs, testGuild)
+	if err != nil {
+		t.Errorf("Could not get schema version: %v", err)
+		return
+	}
+	if version != CurrentSchemaVersion() {
+		t.Errorf("Expected version %d, Got %d", CurrentSchemaVersion(), version)
+	}
+}
+
+func TestMigrateSchema(t *testing.T) {
+	kvs := OpenMemory()
+	if err := kvs.Set(testGuild, col, "some arbituary key", 1); err != nil {
+		t.Errorf("Could not set test input value: %v", err)
+		return
+	}
+	if err := kvs.Delete(testGuild, schemaCollection, schemaVersionKey); err != nil {
+		t.Errorf("Could not pretend to be an old guild: %v", err)
+		return
+	}
+
+	pending, err := MigrateSchema(kvs, testGuild, true)
+	if err != nil {
+		t.Errorf("Dry run failed: %v", err)
+		return
+	}
+	if pending != len(schemaMigrations) {
+		t.Errorf("Expected %d pending migrations, Got %d", len(schemaMigrations), pending)
+	}
+	if version, _ := SchemaVersion(kvs, testGuild); version != 0 {
+		t.Errorf("Dry run changed the version to %d", version)
+	}
+
+	applied, err := MigrateSchema(kvs, testGuild, false)
+	if err != nil {
+		t.Errorf("Migration failed: %v", err)
+		return
+	}
+	if applied != pending {
+		t.Errorf("Expected %d applied migrations, Got %d", pending, applied)
+	}
+	if version, _ := SchemaVersion(kvs, testGuild); version != CurrentSchemaVersion() {
+		t.Errorf("Expected version %d, Got %d", CurrentSchemaVersion(), version)
+	}
+}
+
+func TestMigrateVoteExpiry(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		// A vote with no channel, stored under a key its MessageID doesn't match.
+		if err := kvs.Set(testGuild, "votes", "1234", Vote{Question: "Broken?"}); err != nil {
+			t.Errorf("Could not set test input value: %v", err)
+			return
+		}
+		// A good vote that has already ended, also stored under a key its MessageID doesn't match.
+		ended := Vote{EndTime: time.Now().Add(-time.Hour).Unix(), ChannelID: 42, MessageID: 5678, Question: "Mismatched?"}
+		if err := kvs.Set(testGuild, "votes", "4321", ended); err != nil {
+			t.Errorf("Could not set test input value: %v", err)
+			return
+		}
+		if err := kvs.Batch(func(tx Tx) error { return migrateVoteExpiry(tx, testGuild) }); err != nil {
+			t.Errorf("Migration failed: %v", err)
+			return
+		}
+		if exist, _ := kvs.Get(testGuild, "votes", "1234", &Vote{}); exist {
+			t.Error("Expected the vote with no channel to be purged")
+		}
+		keys, _ := kvs.Keys(testGuild, "votes")
+		if len(keys) != 1 || keys[0] != "4321" {
+			t.Errorf("Expected the ended vote to stay under the key it was found under, Got %v", keys)
+		}
+		expired, err := kvs.Expired(time.Now())
+		if err != nil || len(expired) != 1 || expired[0].Key != "4321" {
+			t.Errorf("Expected the ended vote to be picked up by the sweeper, Got %v (%v)", expired, err)
+		}
+	})
+}
@@ -12,18 +12,49 @@ import (
 )
 
 // See saves the given user as being seen in the given guild.
-func See(kvs KeyValueStore, guildID discord.GuildID, userID discord.UserID) error {
+func See(kvs Tx, guildID discord.GuildID, userID discord.UserID) error {
 	return kvs.Set(guildID, "seen", userID, time.Now().Unix())
 }
 
-// LastSeen checks to see when the given user was seen in the given guild.
+// LastSeen checks to see when the given user was seen in the given guild, including anything not yet flushed.
 func LastSeen(kvs KeyValueStore, guildID discord.GuildID, userID discord.UserID) (bool, int64, error) {
+	if seenTimestamp, buffered := bufferedSeen(guildID, userID); buffered {
+		return true, seenTimestamp, nil // Always newer than what's stored.
+	}
 	var seenTimestamp int64
 	exist, err := kvs.Get(guildID, "seen", userID, &seenTimestamp)
 	return exist, seenTimestamp, err
 
 }
 
+// AllSeen reads every seen timestamp for the given guild in one go, which beats calling LastSeen for each member.
+// Like LastSeen, it includes anything not yet flushed.
+func AllSeen(kvs KeyValueStore, guildID discord.GuildID) (seen map[discord.UserID]int64, err error) {
+	seen = bufferedSeenGuild(guildID)
+	err = kvs.ForEach(guildID, "seen", func(key string, value ValueDecoder) error {
+		snowflake, err := discord.ParseSnowflake(key)
+		if err != nil {
+			log.Printf("[%s] Ignoring seen entry with a strange key %q", guildID, key)
+			return nil
+		}
+		var seenTimestamp int64
+		if err := value(&seenTimestamp); err != nil {
+			return fmt.Errorf("could not decode seen timestamp for %s: %w", key, err)
+		}
+		if _, buffered := seen[discord.UserID(snowflake)]; !buffered {
+			seen[discord.UserID(snowflake)] = seenTimestamp
+		}
+		return nil
+	})
+	return seen, err
+}
+
+// The active role is given to anyone who says anything, and taken away again after they have been quiet for a while.
+var (
+	activeRole      = RoleSetting("activerole.role", "Role given to anyone who says anything, and revoked when they go quiet")
+	activeRoleAfter = DurationSetting("activerole.after", "How long someone has to be quiet to lose the active role, 0 to never revoke it", 30*24*time.Hour)
+)
+
 func MaybeGiveActiveRole(kvs KeyValueStore, state *state.State, guildID discord.GuildID, member *discord.Member) (err error) {
 
 	if member == nil {
@@ -33,10 +64,9 @@ func MaybeGiveActiveRole(kvs KeyValueStore, state *state.State, guildID discord.
 		return nil // Bots aren't "active" as such.
 	}
 
-	role := discord.NullRoleID
-	exist, err := kvs.Get(guildID, "activerole", "role", &role)
+	role, exist, err := activeRole.Get(kvs, guildID)
 	if err != nil {
-		return fmt.Errorf("MaybeGiveActiveRole GetObject: %w", err)
+		return fmt.Errorf("MaybeGiveActiveRole setting lookup: %w", err)
 	}
 
 	if exist {
@@ -52,10 +82,9 @@ func MaybeGiveActiveRole(kvs KeyValueStore, state *state.State, guildID discord.
 }
 
 func RemoveActiveRole(kvs KeyValueStore, state *state.State, guildID discord.GuildID, member *discord.Member) error {
-	role := discord.NullRoleID
-	exist, err := kvs.Get(guildID, "activerole", "role", &role)
+	role, exist, err := activeRole.Get(kvs, guildID)
 	if err != nil {
-		return fmt.Errorf("RemoveActiveRole GetObject: %w", err)
+		return fmt.Errorf("RemoveActiveRole setting lookup: %w", err)
 	}
 
 	if exist {
@@ -73,38 +102,38 @@ func RevokeActiveRoles(state *state.State, kvs KeyValueStore) error {
 	if err != nil {
 		return fmt.Errorf("revoking active roles failed to get guilds slice: %w", err)
 	}
-	now := time.Now().Unix()
-	secondsInDay := float64(24 * 60 * 60)
+	now := time.Now()
 	for _, guild := range guilds {
-		role := discord.NullRoleID
-		exist, err := kvs.Get(guild.ID, "activerole", "role", &role)
+		role, exist, err := activeRole.Get(kvs, guild.ID)
 		if err != nil {
-			log.Printf("[%s] Failed to fetch the active role object: %s\n", guild.ID, err)
+			log.Printf("[%s] Failed to fetch the active role setting: %s\n", guild.ID, err)
 		}
 		if !exist {
 			continue // Because if the role isn't set, this guild has no "active role"
 		}
 
-		var days float64
-		exist, err = kvs.Get(guild.ID, "activerole", "days", &days)
+		after, _, err := activeRoleAfter.Get(kvs, guild.ID)
 		if err != nil {
 			log.Printf("[%s] Failed to fetch the active role time: %s\n", guild.ID, err)
-		}
-		if !exist {
 			continue
 		}
-		inactiveIfSeenBefore := now - int64(days*secondsInDay)
+		if after <= 0 {
+			continue // Once active, always active.
+		}
+		inactiveIfSeenBefore := now.Add(-after).Unix()
 
 		members, err := state.Session.Members(guild.ID, 0)
 		if err != nil {
 			log.Printf("[%s] Failed to fetch the member list: %s\n", guild.ID, err)
 			continue
 		}
+		seen, err := AllSeen(kvs, guild.ID)
+		if err != nil {
+			log.Printf("[%s] Failed to fetch seen data: %s", guild.ID, err)
+			continue
+		}
 		for _, member := range members {
-			wasSeen, when, err := LastSeen(kvs, guild.ID, member.User.ID)
-			if err != nil {
-				log.Printf("[%s] Failed to fetch seen data for member: %s", guild.ID, err)
-			}
+			when, wasSeen := seen[member.User.ID]
 			if !wasSeen || when < inactiveIfSeenBefore {
 				if utility.ContainsRole(member.RoleIDs, role) {
 					err := state.RemoveRole(guild.ID, member.User.ID, role, api.AuditLogReason("Role automatically revoked for chat inactivity."))
@@ -120,11 +149,9 @@ func RevokeActiveRoles(state *state.State, kvs KeyValueStore) error {
 }
 
 func StartRevokingActiveRole(state *state.State, kvs KeyValueStore) {
-	ticker := time.NewTicker(10 * time.Minute)
-	for {
-		<-ticker.C
+	tickEvery(func(cfg Configuration) time.Duration { return cfg.Intervals.ActiveRole.Value() }, func() {
 		if err := RevokeActiveRoles(state, kvs); err != nil {
 			log.Printf("Error encountered trying to revoke active roles: %s\n", err)
 		}
-	}
+	})
 }
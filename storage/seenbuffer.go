@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// seenBuffer holds seen timestamps that have yet to be written, so a busy guild costs one write per flush instead of one per message.
+// Only the newest timestamp per guild and user is kept.
+// While a flush is being written, its timestamps are kept in flushing, so they can still be found until they are in the store.
+var seenBuffer = struct {
+	mutex      sync.Mutex
+	flushMutex sync.Mutex // Only one FlushSeen at a time, so there's only ever one flushing.
+	pending    map[discord.GuildID]map[discord.UserID]int64
+	flushing   map[discord.GuildID]map[discord.UserID]int64
+}{
+	pending: map[discord.GuildID]map[discord.UserID]int64{},
+}
+
+// SeeBuffered marks the given user as being seen in the given guild right now, without writing it until the next FlushSeen.
+func SeeBuffered(guildID discord.GuildID, userID discord.UserID) {
+	seenBuffer.mutex.Lock()
+	defer seenBuffer.mutex.Unlock()
+	bufferSeen(guildID, userID, time.Now().Unix())
+}
+
+// bufferSeen keeps the timestamp, unless a newer one is already waiting. The caller must hold the lock.
+func bufferSeen(guildID discord.GuildID, userID discord.UserID, seenTimestamp int64) {
+	guild, ok := seenBuffer.pending[guildID]
+	if !ok {
+		guild = map[discord.UserID]int64{}
+		seenBuffer.pending[guildID] = guild
+	}
+	if seenTimestamp > guild[userID] {
+		guild[userID] = seenTimestamp
+	}
+}
+
+// bufferedSeen looks up a seen timestamp that has yet to be written, or is being written right now.
+func bufferedSeen(guildID discord.GuildID, userID discord.UserID) (seenTimestamp int64, buffered bool) {
+	seenBuffer.mutex.Lock()
+	defer seenBuffer.mutex.Unlock()
+	for _, buffer := range []map[discord.GuildID]map[discord.UserID]int64{seenBuffer.pending, seenBuffer.flushing} {
+		if candidate, ok := buffer[guildID][userID]; ok && candidate > seenTimestamp {
+			seenTimestamp, buffered = candidate, true
+		}
+	}
+	return
+}
+
+// bufferedSeenGuild copies every seen timestamp for the given guild that has yet to be written, or is being written right now.
+func bufferedSeenGuild(guildID discord.GuildID) map[discord.UserID]int64 {
+	seenBuffer.mutex.Lock()
+	defer seenBuffer.mutex.Unlock()
+	guild := map[discord.UserID]int64{}
+	for _, buffer := range []map[discord.GuildID]map[discord.UserID]int64{seenBuffer.pending, seenBuffer.flushing} {
+		for userID, seenTimestamp := range buffer[guildID] {
+			if seenTimestamp > guild[userID] {
+				guild[userID] = seenTimestamp
+			}
+		}
+	}
+	return guild
+}
+
+// FlushSeen writes every buffered seen timestamp in a single Batch.
+// If that fails, the timestamps go back in the buffer to be tried again next time.
+func FlushSeen(kvs KeyValueStore) error {
+	seenBuffer.flushMutex.Lock()
+	defer seenBuffer.flushMutex.Unlock()
+	seenBuffer.mutex.Lock()
+	flushing := seenBuffer.pending
+	seenBuffer.pending = map[discord.GuildID]map[discord.UserID]int64{}
+	seenBuffer.flushing = flushing
+	seenBuffer.mutex.Unlock()
+	if len(flushing) == 0 {
+		return nil
+	}
+
+	count := 0
+	err := kvs.Batch(func(tx Tx) error {
+		count = 0
+		for guildID, guild := range flushing {
+			for userID, seenTimestamp := range guild {
+				if err := tx.Set(guildID, "seen", userID, seenTimestamp); err != nil {
+					return err
+				}
+				count++
+			}
+		}
+		return nil
+	})
+	seenBuffer.mutex.Lock()
+	seenBuffer.flushing = nil
+	if err != nil {
+		for guildID, guild := range flushing {
+			for userID, seenTimestamp := range guild {
+				bufferSeen(guildID, userID, seenTimestamp)
+			}
+		}
+	}
+	seenBuffer.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("could not flush seen timestamps: %w", err)
+	}
+	log.Printf("Flushed %d seen timestamps across %d guilds", count, len(flushing))
+	return nil
+}
+
+// StartFlushingSeen calls FlushSeen at the configured interval.
+// Intended to be called as a goroutine. Remember to call FlushSeen once more when shutting down.
+func StartFlushingSeen(kvs KeyValueStore) {
+	tickEvery(func(cfg Configuration) time.Duration { return cfg.Intervals.SeenFlush.Value() }, func() {
+		if err := FlushSeen(kvs); err != nil {
+			log.Printf("Error encountered flushing seen timestamps: %s\n", err)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+func TestSeenBuffer(t *testing.T) {
+	kvs := OpenMemory()
+	t.Cleanup(func() {
+		FlushSeen(kvs)
+	})
+	userID := discord.UserID(123)
+	stale := discord.UserID(456)
+	if err := kvs.Set(testGuild, "seen", stale, int64(1)); err != nil {
+		t.Errorf("Could not set test input value: %v", err)
+		return
+	}
+
+	SeeBuffered(testGuild, userID)
+	SeeBuffered(testGuild, stale)
+
+	var stored int64
+	if exist, _ := kvs.Get(testGuild, "seen", userID, &stored); exist {
+		t.Error("Buffered timestamp was written before flushing")
+	}
+	exist, when, err := LastSeen(kvs, testGuild, userID)
+	if err != nil || !exist || when == 0 {
+		t.Errorf("Expected LastSeen to read through the buffer, Got %v %d (%v)", exist, when, err)
+	}
+	seen, err := AllSeen(kvs, testGuild)
+	if err != nil || len(seen) != 2 || seen[stale] == 1 {
+		t.Errorf("Expected AllSeen to prefer the buffer, Got %v (%v)", seen, err)
+	}
+
+	if err := FlushSeen(kvs); err != nil {
+		t.Errorf("Flush failed: %v", err)
+		return
+	}
+	if exist, _ := kvs.Get(testGuild, "seen", userID, &stored); !exist || stored != when {
+		t.Errorf("Expected %d to be written by the flush, Got %d", when, stored)
+	}
+	if _, buffered := bufferedSeen(testGuild, userID); buffered {
+		t.Error("Buffer was not emptied by the flush")
+	}
+}
+
+// hookedBatchStore runs a function just before each Batch, to look at things while a flush is underway.
+type hookedBatchStore struct {
+	KeyValueStore
+	before func()
+}
+
+func (store hookedBatchStore) Batch(fn func(tx Tx) error) error {
+	store.before()
+	return store.KeyValueStore.Batch(fn)
+}
+
+func TestSeenBufferDuringFlush(t *testing.T) {
+	kvs := OpenMemory()
+	userID := discord.UserID(789)
+	SeeBuffered(testGuild, userID)
+	checked := false
+	hooked := hookedBatchStore{kvs, func() {
+		checked = true
+		if exist, when, err := LastSeen(kvs, testGuild, userID); err != nil || !exist || when == 0 {
+			t.Errorf("Expected LastSeen to find the timestamp being flushed, Got %v %d (%v)", exist, when, err)
+		}
+		if seen, err := AllSeen(kvs, testGuild); err != nil || seen[userID] == 0 {
+			t.Errorf("Expected AllSeen to find the timestamp being flushed, Got %v (%v)", seen, err)
+		}
+	}}
+	if err := FlushSeen(hooked); err != nil {
+		t.Errorf("Flush failed: %v", err)
+		return
+	}
+	if !checked {
+		t.Error("Expected the flush to use a Batch")
+	}
+}
@@ -0,0 +1,374 @@
+package storage
+
+import (
+	"fmt"
+	"komainu/utility"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// settingsCollection holds every registered guild setting, keyed by setting name.
+const settingsCollection = "settings"
+
+// SettingKind tells what type of value a setting holds.
+type SettingKind string
+
+const (
+	SettingChannel  SettingKind = "channel"
+	SettingRole     SettingKind = "role"
+	SettingDuration SettingKind = "duration"
+	SettingBool     SettingKind = "bool"
+	SettingString   SettingKind = "string"
+	SettingRate     SettingKind = "rate"
+	SettingNumber   SettingKind = "number"
+)
+
+// GuildSetting is what the settings registry knows about every setting, whatever type it holds.
+// The text based methods are for /config, features should use the typed Setting they declared.
+type GuildSetting interface {
+	Name() string
+	Description() string
+	Kind() SettingKind
+	// Show formats the value for the given guild, and tells if it was set or is the default.
+	Show(kvs KeyValueStore, guildID discord.GuildID) (shown string, isSet bool, err error)
+	// SetText parses the given text as a value for the setting, and stores it. Returns the value as Show would.
+	SetText(tx Tx, guildID discord.GuildID, text string) (shown string, err error)
+	// Reset removes the value for the given guild, so the default is used again.
+	Reset(tx Tx, guildID discord.GuildID) error
+	// newValue returns a fresh reference to decode a stored value of the setting into, for export and import.
+	newValue() any
+}
+
+// Setting is a typed guild setting, declared by a feature with one of ChannelSetting, RoleSetting, DurationSetting, BoolSetting, StringSetting, RateSetting or NumberSetting.
+type Setting[T any] struct {
+	name        string
+	description string
+	kind        SettingKind
+	fallback    T
+	parse       func(text string) (T, error)
+	format      func(value T) string
+	cache       *ValueCache[T]
+}
+
+func init() {
+	RegisterSchemaMigration(SchemaMigration{
+		Version:     3,
+		Description: "Move feature settings into the settings collection",
+		Migrate:     migrateSettings,
+	})
+}
+
+// guildSettings is the registry of every declared setting.
+var guildSettings = map[string]GuildSetting{}
+
+// registerSetting completes the given setting and adds it to the registry. Names must be unique.
+func registerSetting[T any](setting *Setting[T]) *Setting[T] {
+	if existing, ok := guildSettings[setting.name]; ok {
+		log.Fatalf("Setting %q registered twice: %q and %q", setting.name, existing.Description(), setting.description)
+	}
+	setting.cache = NewValueCache[T](settingsCollection, setting.name)
+	guildSettings[setting.name] = setting
+	return setting
+}
+
+// GuildSettings returns every registered setting, sorted by name.
+func GuildSettings() []GuildSetting {
+	all := make([]GuildSetting, 0, len(guildSettings))
+	for _, setting := range guildSettings {
+		all = append(all, setting)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name() < all[j].Name() })
+	return all
+}
+
+// LookupSetting finds a registered setting by name.
+func LookupSetting(name string) (setting GuildSetting, ok bool) {
+	setting, ok = guildSettings[name]
+	return
+}
+
+// ChannelSetting declares a setting holding a channel. It has no default, so features treat it being unset as being disabled.
+func ChannelSetting(name string, description string) *Setting[discord.ChannelID] {
+	return registerSetting(&Setting[discord.ChannelID]{
+		name:        name,
+		description: description,
+		kind:        SettingChannel,
+		fallback:    discord.NullChannelID,
+		parse: func(text string) (discord.ChannelID, error) {
+			snowflake, err := parseMention(text, "<#")
+			return discord.ChannelID(snowflake), err
+		},
+		format: func(channelID discord.ChannelID) string {
+			if !channelID.IsValid() {
+				return "none"
+			}
+			return channelID.Mention()
+		},
+	})
+}
+
+// RoleSetting declares a setting holding a role. It has no default, so features treat it being unset as being disabled.
+func RoleSetting(name string, description string) *Setting[discord.RoleID] {
+	return registerSetting(&Setting[discord.RoleID]{
+		name:        name,
+		description: description,
+		kind:        SettingRole,
+		fallback:    discord.NullRoleID,
+		parse: func(text string) (discord.RoleID, error) {
+			snowflake, err := parseMention(text, "<@&")
+			return discord.RoleID(snowflake), err
+		},
+		format: func(roleID discord.RoleID) string {
+			if !roleID.IsValid() {
+				return "none"
+			}
+			return roleID.Mention()
+		},
+	})
+}
+
+// DurationSetting declares a setting holding a duration, with the given default.
+func DurationSetting(name string, description string, fallback time.Duration) *Setting[time.Duration] {
+	return registerSetting(&Setting[time.Duration]{
+		name:        name,
+		description: description,
+		kind:        SettingDuration,
+		fallback:    fallback,
+		parse:       parseSettingDuration,
+		format:      formatSettingDuration,
+	})
+}
+
+// BoolSetting declares a setting that is either on or off, with the given default.
+func BoolSetting(name string, description string, fallback bool) *Setting[bool] {
+	return registerSetting(&Setting[bool]{
+		name:        name,
+		description: description,
+		kind:        SettingBool,
+		fallback:    fallback,
+		parse: func(text string) (bool, error) {
+			switch strings.ToLower(text) {
+			case "on", "yes", "enable", "enabled":
+				return true, nil
+			case "off", "no", "disable", "disabled":
+				return false, nil
+			}
+			return strconv.ParseBool(text)
+		},
+		format: func(value bool) string {
+			if value {
+				return "on"
+			}
+			return "off"
+		},
+	})
+}
+
+// StringSetting declares a setting holding some text, with the given default.
+func StringSetting(name string, description string, fallback string) *Setting[string] {
+	return registerSetting(&Setting[string]{
+		name:        name,
+		description: description,
+		kind:        SettingString,
+		fallback:    fallback,
+		parse:       func(text string) (string, error) { return text, nil },
+		format:      strconv.Quote,
+	})
+}
+
+// RateSetting declares a setting holding how many times something can be done in how long, written like "5/10s".
+// It has no default, so features fall back to something else when it is unset.
+func RateSetting(name string, description string) *Setting[utility.Limit] {
+	return registerSetting(&Setting[utility.Limit]{
+		name:        name,
+		description: description,
+		kind:        SettingRate,
+		parse:       parseSettingRate,
+		format: func(limit utility.Limit) string {
+			if limit.Max < 1 {
+				return "the bot's default"
+			}
+			return fmt.Sprintf("%d/%s", limit.Max, formatSettingDuration(limit.Interval))
+		},
+	})
+}
+
+// NumberSetting declares a setting holding a whole number that can't be negative, with the given default.
+func NumberSetting(name string, description string, fallback int) *Setting[int] {
+	return registerSetting(&Setting[int]{
+		name:        name,
+		description: description,
+		kind:        SettingNumber,
+		fallback:    fallback,
+		parse: func(text string) (int, error) {
+			value, err := strconv.Atoi(text)
+			if err == nil && value < 0 {
+				err = fmt.Errorf("%q is negative", text)
+			}
+			return value, err
+		},
+		format: strconv.Itoa,
+	})
+}
+
+func (s *Setting[T]) Name() string        { return s.name }
+func (s *Setting[T]) Description() string { return s.description }
+func (s *Setting[T]) Kind() SettingKind   { return s.kind }
+
+func (s *Setting[T]) newValue() any { return new(T) }
+
+// Get returns the value for the given guild, or the default if it isn't set. Values are cached, so this is cheap.
+func (s *Setting[T]) Get(kvs KeyValueStore, guildID discord.GuildID) (value T, isSet bool, err error) {
+	value, isSet, err = s.cache.Get(kvs, guildID)
+	if err != nil || !isSet {
+		return s.fallback, false, err
+	}
+	return value, true, nil
+}
+
+// Set stores the value for the given guild.
+func (s *Setting[T]) Set(tx Tx, guildID discord.GuildID, value T) error {
+	return tx.Set(guildID, settingsCollection, s.name, value)
+}
+
+// Reset removes the value for the given guild, so the default is used again.
+func (s *Setting[T]) Reset(tx Tx, guildID discord.GuildID) error {
+	return tx.Delete(guildID, settingsCollection, s.name)
+}
+
+// Show formats the value for the given guild, and tells if it was set or is the default.
+func (s *Setting[T]) Show(kvs KeyValueStore, guildID discord.GuildID) (shown string, isSet bool, err error) {
+	value, isSet, err := s.Get(kvs, guildID)
+	if err != nil {
+		return "", false, err
+	}
+	return s.format(value), isSet, nil
+}
+
+// SetText parses the given text as a value for the setting, and stores it.
+func (s *Setting[T]) SetText(tx Tx, guildID discord.GuildID, text string) (shown string, err error) {
+	value, err := s.parse(strings.TrimSpace(text))
+	if err != nil {
+		return "", fmt.Errorf("not a valid %s: %w", s.kind, err)
+	}
+	if err := s.Set(tx, guildID, value); err != nil {
+		return "", err
+	}
+	return s.format(value), nil
+}
+
+// parseMention reads a snowflake given either as a plain number or as a mention starting with the given prefix.
+func parseMention(text string, prefix string) (discord.Snowflake, error) {
+	text = strings.TrimSuffix(strings.TrimPrefix(text, prefix), ">")
+	snowflake, err := discord.ParseSnowflake(text)
+	if err != nil {
+		return discord.NullSnowflake, err
+	}
+	if !snowflake.IsValid() {
+		return discord.NullSnowflake, fmt.Errorf("%q is not a valid ID", text)
+	}
+	return snowflake, nil
+}
+
+// parseSettingDuration reads a duration the way time.ParseDuration does, but also understands a number of days, like "30d".
+func parseSettingDuration(text string) (duration time.Duration, err error) {
+	if strings.HasSuffix(text, "d") {
+		count, parseErr := strconv.ParseFloat(strings.TrimSuffix(text, "d"), 64)
+		duration, err = time.Duration(count*float64(24*time.Hour)), parseErr
+	} else {
+		duration, err = time.ParseDuration(text)
+	}
+	if err == nil && duration < 0 {
+		err = fmt.Errorf("%q is negative", text)
+	}
+	return
+}
+
+// parseSettingRate reads a count and a duration separated by a slash, like "5/10s" or "100/1d".
+func parseSettingRate(text string) (limit utility.Limit, err error) {
+	count, duration, found := strings.Cut(text, "/")
+	if !found {
+		return limit, fmt.Errorf("%q should be a count and a duration, like 5/10s", text)
+	}
+	if limit.Max, err = strconv.Atoi(strings.TrimSpace(count)); err != nil {
+		return limit, err
+	}
+	if limit.Max < 1 {
+		return limit, fmt.Errorf("%q should allow at least 1", text)
+	}
+	if limit.Interval, err = parseSettingDuration(strings.TrimSpace(duration)); err != nil {
+		return limit, err
+	}
+	if limit.Interval == 0 {
+		return limit, fmt.Errorf("%q needs a duration longer than 0", text)
+	}
+	return limit, nil
+}
+
+// formatSettingDuration formats whole days as days, as that's how most of the durations are chosen.
+func formatSettingDuration(duration time.Duration) string {
+	day := 24 * time.Hour
+	if duration >= day && duration%day == 0 {
+		return fmt.Sprintf("%dd", duration/day)
+	}
+	return duration.String()
+}
+
+// migrateGuildSettings runs migrateSettings on a guild outside of the schema migrations,
+// for data written the old way after the guild was already stamped with the current schema version, like an old export being imported.
+func migrateGuildSettings(kvs KeyValueStore, guildID discord.GuildID) error {
+	return kvs.Batch(func(tx Tx) error {
+		return migrateSettings(tx, guildID)
+	})
+}
+
+// migrateSettings moves the settings that each feature used to keep in its own collection into the settings collection.
+func migrateSettings(tx Tx, guildID discord.GuildID) error {
+	for _, feature := range []string{"deletelog", "trafficlog"} {
+		if _, err := moveSetting[discord.ChannelID](tx, guildID, feature, "channel", feature+".channel"); err != nil {
+			return err
+		}
+	}
+	roleMoved, err := moveSetting[discord.RoleID](tx, guildID, "activerole", "role", "activerole.role")
+	if err != nil {
+		return err
+	}
+	var days float64
+	exist, err := tx.Get(guildID, "activerole", "days", &days)
+	if err != nil {
+		return fmt.Errorf("could not read activerole/days: %w", err)
+	}
+	if !exist {
+		if roleMoved {
+			// Without a number of days, the active role used to never be revoked. That stays so, rather than picking up the default.
+			return tx.Set(guildID, settingsCollection, "activerole.after", time.Duration(0))
+		}
+		return nil
+	}
+	if err := tx.Set(guildID, settingsCollection, "activerole.after", time.Duration(days*float64(24*time.Hour))); err != nil {
+		return err
+	}
+	return tx.Delete(guildID, "activerole", "days")
+}
+
+// moveSetting moves a single value from where a feature used to keep it into the settings collection.
+// Returns whether there was anything to move.
+func moveSetting[T any](tx Tx, guildID discord.GuildID, collection string, key string, name string) (moved bool, err error) {
+	var value T
+	exist, err := tx.Get(guildID, collection, key, &value)
+	if err != nil {
+		return false, fmt.Errorf("could not read %s/%s: %w", collection, key, err)
+	}
+	if !exist {
+		return false, nil
+	}
+	if err := tx.Set(guildID, settingsCollection, name, value); err != nil {
+		return false, err
+	}
+	return true, tx.Delete(guildID, collection, key)
+}
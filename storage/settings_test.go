@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+func TestSettings(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		after, isSet, err := activeRoleAfter.Get(kvs, testGuild)
+		if err != nil || isSet || after != 30*24*time.Hour {
+			t.Errorf("Expected the 30 day default, Got %s (set: %t, %v)", after, isSet, err)
+			return
+		}
+
+		setting, ok := LookupSetting("activerole.after")
+		if !ok {
+			t.Error("Expected activerole.after to be registered")
+			return
+		}
+		for _, tc := range []struct{ text, expected string }{{"12h", "12h0m0s"}, {"1.5d", "36h0m0s"}, {"2d", "2d"}} {
+			text, expected := tc.text, tc.expected
+			var shown string
+			err := kvs.Batch(func(tx Tx) (err error) {
+				shown, err = setting.SetText(tx, testGuild, text)
+				return
+			})
+			if err != nil || shown != expected {
+				t.Errorf("Expected %q to be shown as %s, Got %s (%v)", text, expected, shown, err)
+			}
+		}
+		if after, isSet, _ := activeRoleAfter.Get(kvs, testGuild); !isSet || after != 48*time.Hour {
+			t.Errorf("Expected the cached value to follow the change to 48h, Got %s (set: %t)", after, isSet)
+		}
+		for _, bad := range []string{"soon", "-1d", ""} {
+			err := kvs.Batch(func(tx Tx) error {
+				_, err := setting.SetText(tx, testGuild, bad)
+				return err
+			})
+			if err == nil {
+				t.Errorf("Expected %q to be refused", bad)
+			}
+		}
+
+		if err := kvs.Batch(func(tx Tx) error { return setting.Reset(tx, testGuild) }); err != nil {
+			t.Errorf("Could not reset: %v", err)
+			return
+		}
+		if shown, isSet, err := setting.Show(kvs, testGuild); err != nil || isSet || shown != "30d" {
+			t.Errorf("Expected the default to be back, Got %s (set: %t, %v)", shown, isSet, err)
+		}
+
+		role, _ := LookupSetting("activerole.role")
+		err = kvs.Batch(func(tx Tx) error {
+			_, err := role.SetText(tx, testGuild, "<@&1234>")
+			return err
+		})
+		if err != nil {
+			t.Errorf("Could not set the role: %v", err)
+		}
+		if roleID, isSet, _ := activeRole.Get(kvs, testGuild); !isSet || roleID != 1234 {
+			t.Errorf("Expected role 1234, Got %s (set: %t)", roleID, isSet)
+		}
+	})
+}
+
+func TestParseSettingRate(t *testing.T) {
+	limit, err := parseSettingRate("5 / 1d")
+	if err != nil || limit.Max != 5 || limit.Interval != 24*time.Hour {
+		t.Errorf("Expected 5 per day, Got %d per %s (%v)", limit.Max, limit.Interval, err)
+	}
+	for _, bad := range []string{"5", "five/10s", "0/10s", "5/0s", "5/-1m"} {
+		if _, err := parseSettingRate(bad); err == nil {
+			t.Errorf("Expected %q to be refused", bad)
+		}
+	}
+}
+
+func TestMigrateSettings(t *testing.T) {
+	kvs := OpenMemory()
+	kvs.Set(testGuild, "deletelog", "channel", discord.ChannelID(11))
+	kvs.Set(testGuild, "activerole", "role", discord.RoleID(22))
+	kvs.Set(testGuild, "activerole", "days", 1.5)
+	if err := kvs.Batch(func(tx Tx) error { return migrateSettings(tx, testGuild) }); err != nil {
+		t.Errorf("Migration failed: %v", err)
+		return
+	}
+
+	var moved discord.ChannelID
+	if _, err := kvs.Get(testGuild, settingsCollection, "deletelog.channel", &moved); err != nil || moved != 11 {
+		t.Errorf("Expected the delete log channel to move, Got %s (%v)", moved, err)
+	}
+	if role, _, _ := activeRole.Get(kvs, testGuild); role != 22 {
+		t.Errorf("Expected the active role to move, Got %s", role)
+	}
+	if after, _, _ := activeRoleAfter.Get(kvs, testGuild); after != 36*time.Hour {
+		t.Errorf("Expected 1.5 days to become 36h, Got %s", after)
+	}
+	if exist, _ := kvs.Get(testGuild, "activerole", "days", new(float64)); exist {
+		t.Error("Expected the old activerole/days to be gone")
+	}
+	if exist, _ := kvs.Get(testGuild, settingsCollection, "trafficlog.channel", new(discord.ChannelID)); exist {
+		t.Error("Expected no traffic log channel to appear out of nowhere")
+	}
+
+	// A guild with an active role but no days never had it revoked, and shouldn't start to.
+	kvs.Set(testGuild+1, "activerole", "role", discord.RoleID(33))
+	if err := kvs.Batch(func(tx Tx) error { return migrateSettings(tx, testGuild+1) }); err != nil {
+		t.Errorf("Migration failed: %v", err)
+		return
+	}
+	if after, isSet, _ := activeRoleAfter.Get(kvs, testGuild+1); !isSet || after != 0 {
+		t.Errorf("Expected the active role to still never be revoked, Got %s (set: %t)", after, isSet)
+	}
+	// Running it again, like an import does, leaves the settings alone.
+	activeRoleAfter.Set(kvs, testGuild+1, 48*time.Hour)
+	if err := kvs.Batch(func(tx Tx) error { return migrateSettings(tx, testGuild+1) }); err != nil {
+		t.Errorf("Migration failed: %v", err)
+		return
+	}
+	if after, _, _ := activeRoleAfter.Get(kvs, testGuild+1); after != 48*time.Hour {
+		t.Errorf("Expected migrating again to change nothing, Got %s", after)
+	}
+}
@@ -0,0 +1,371 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema lays everything out in a single table, so the guild/collection/key structure can be queried with plain SQL.
+// Values are encoded just like komainuBolt does it, and expiries live in the expiry collection, just like there.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS kv (
+	guild      TEXT NOT NULL,
+	collection TEXT NOT NULL,
+	key        TEXT NOT NULL,
+	value      BLOB NOT NULL,
+	PRIMARY KEY (guild, collection, key)
+) WITHOUT ROWID;
+`
+
+// komainuSQLite is a KeyValueStore backed by an SQLite database.
+// Unlike bolt, the database can be read by other programs (like the sqlite3 shell) while Komainu is running.
+type komainuSQLite struct {
+	db *sql.DB
+	// writer makes sure only one Batch is open at a time, so they never trip over each other's locks.
+	writer sync.Mutex
+	watchers
+}
+
+func OpenKomainuSQLite(path string) (*komainuSQLite, error) {
+	dsn := "file:" + path + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not create sqlite schema: %w", err)
+	}
+	ks := &komainuSQLite{db: db}
+	guilds, err := ks.guilds()
+	if err != nil {
+		ks.Close()
+		return nil, fmt.Errorf("could not list guilds for schema migration: %w", err)
+	}
+	if err := MigrateSchemas(ks, guilds, SchemaDryRun); err != nil {
+		ks.Close()
+		return nil, err
+	}
+	return ks, nil
+}
+
+// guilds lists the guilds that have anything stored at all.
+func (ks *komainuSQLite) guilds() (guilds []discord.GuildID, err error) {
+	rows, err := ks.db.Query(`SELECT DISTINCT guild FROM kv ORDER BY guild`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		snowflake, err := discord.ParseSnowflake(name)
+		if err != nil {
+			log.Printf("Ignoring guild %q, as it is not a guild ID", name)
+			continue
+		}
+		guilds = append(guilds, discord.GuildID(snowflake))
+	}
+	return guilds, rows.Err()
+}
+
+// komainuSQLiteTx is a Tx backed by a single SQLite transaction.
+type komainuSQLiteTx struct {
+	tx      *sql.Tx
+	changes []ChangeEvent
+}
+
+// put stores a raw value, stamping the schema version first if this is a brand new guild.
+func (kst *komainuSQLiteTx) put(guild string, collection string, key string, value []byte) error {
+	var exists bool
+	if err := kst.tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM kv WHERE guild = ?)`, guild).Scan(&exists); err != nil {
+		return fmt.Errorf("sqlite store failed to look up guild: %w", err)
+	}
+	if !exists {
+		version, err := encodeValue(CurrentSchemaVersion())
+		if err != nil {
+			return err
+		}
+		if err := kst.upsert(guild, schemaCollection, schemaVersionKey, version); err != nil {
+			return fmt.Errorf("sqlite store failed to stamp schema version: %w", err)
+		}
+	}
+	return kst.upsert(guild, collection, key, value)
+}
+
+func (kst *komainuSQLiteTx) upsert(guild string, collection string, key string, value []byte) error {
+	_, err := kst.tx.Exec(`INSERT INTO kv (guild, collection, key, value) VALUES (?, ?, ?, ?)
+		ON CONFLICT (guild, collection, key) DO UPDATE SET value = excluded.value`, guild, collection, key, value)
+	return err
+}
+
+// store puts the value in SQLite. If expires is non-zero, the key is due to expire at that unix time, otherwise any expiry is cleared.
+func (kst *komainuSQLiteTx) store(guildID discord.GuildID, collection string, key any, value any, expires int64) error {
+	encoded, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+	if err := kst.put(guildID.String(), collection, keyString(key), encoded); err != nil {
+		return fmt.Errorf("sqlite store failed to store value: %w", err)
+	}
+	return kst.setExpiry(guildID, collection, keyString(key), expires)
+}
+
+// setExpiry records when the given key expires, or forgets about it if expires is zero.
+func (kst *komainuSQLiteTx) setExpiry(guildID discord.GuildID, collection string, key string, expires int64) error {
+	if expires == 0 {
+		return kst.remove(guildID.String(), expiryCollection, expiryKey(collection, key))
+	}
+	encoded, err := encodeValue(expires)
+	if err != nil {
+		return err
+	}
+	if err := kst.put(guildID.String(), expiryCollection, expiryKey(collection, key), encoded); err != nil {
+		return fmt.Errorf("sqlite store failed to store expiry: %w", err)
+	}
+	return nil
+}
+
+func (kst *komainuSQLiteTx) remove(guild string, collection string, key string) error {
+	if _, err := kst.tx.Exec(`DELETE FROM kv WHERE guild = ? AND collection = ? AND key = ?`, guild, collection, key); err != nil {
+		return fmt.Errorf("storage failed to delete: %w", err)
+	}
+	return nil
+}
+
+func (kst *komainuSQLiteTx) Set(guildID discord.GuildID, collection string, key any, value any) (err error) {
+	if err := kst.store(guildID, collection, key, value, 0); err != nil {
+		return err
+	}
+	kst.changes = append(kst.changes, ChangeEvent{guildID, collection, keyString(key), false})
+	return nil
+}
+
+func (kst *komainuSQLiteTx) SetWithTTL(guildID discord.GuildID, collection string, key any, value any, ttl time.Duration) (err error) {
+	if err := kst.store(guildID, collection, key, value, expiryTime(ttl)); err != nil {
+		return err
+	}
+	kst.changes = append(kst.changes, ChangeEvent{guildID, collection, keyString(key), false})
+	return nil
+}
+
+func (kst *komainuSQLiteTx) Get(guildID discord.GuildID, collection string, key any, out any) (found bool, err error) {
+	var raw []byte
+	err = kst.tx.QueryRow(`SELECT value FROM kv WHERE guild = ? AND collection = ? AND key = ?`,
+		guildID.String(), collection, keyString(key)).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("sqlite store failed to get value: %w", err)
+	}
+	return true, decodeValue(raw, out)
+}
+
+func (kst *komainuSQLiteTx) Delete(guildID discord.GuildID, collection string, key any) (err error) {
+	if err := kst.remove(guildID.String(), collection, keyString(key)); err != nil {
+		return err
+	}
+	if err := kst.setExpiry(guildID, collection, keyString(key), 0); err != nil {
+		return err
+	}
+	kst.changes = append(kst.changes, ChangeEvent{guildID, collection, keyString(key), true})
+	return nil
+}
+
+func (kst *komainuSQLiteTx) Keys(guildID discord.GuildID, collection string) (keys []string, err error) {
+	rows, err := kst.tx.Query(`SELECT key FROM kv WHERE guild = ? AND collection = ? ORDER BY key`, guildID.String(), collection)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite store failed to list keys: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (kst *komainuSQLiteTx) ForEach(guildID discord.GuildID, collection string, fn ForEachFunc) (err error) {
+	return kst.Scan(guildID, collection, "", 0, fn)
+}
+
+func (kst *komainuSQLiteTx) Scan(guildID discord.GuildID, collection string, prefix string, limit int, fn ForEachFunc) (err error) {
+	type entry struct {
+		key   string
+		value []byte
+	}
+	// Everything is read up front, so fn is free to use the transaction without tripping over an open query.
+	entries := []entry{}
+	rows, err := kst.tx.Query(`SELECT key, value FROM kv WHERE guild = ? AND collection = ? AND key >= ? ORDER BY key`,
+		guildID.String(), collection, prefix)
+	if err != nil {
+		return fmt.Errorf("sqlite store failed to scan: %w", err)
+	}
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.key, &e.value); err != nil {
+			rows.Close()
+			return err
+		}
+		if !strings.HasPrefix(e.key, prefix) || (limit > 0 && len(entries) >= limit) {
+			break
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		value := e.value
+		if err := fn(e.key, func(out any) error { return decodeValue(value, out) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (kst *komainuSQLiteTx) Increment(guildID discord.GuildID, collection string, key any, delta int64) (count int64, err error) {
+	return increment(kst, guildID, collection, key, delta)
+}
+
+// Batch runs fn in a single SQLite transaction, which is rolled back if fn returns an error.
+// Subscribers hear about the changes once it's committed.
+func (ks *komainuSQLite) Batch(fn func(tx Tx) error) error {
+	ks.writer.Lock()
+	changes, err := ks.transaction(fn)
+	ks.writer.Unlock()
+	if err == nil {
+		ks.notify(changes)
+	}
+	return err
+}
+
+// view runs fn in a single SQLite transaction meant for reading.
+func (ks *komainuSQLite) view(fn func(tx Tx) error) error {
+	_, err := ks.transaction(fn)
+	return err
+}
+
+// transaction runs fn in a single SQLite transaction, returning the changes it made.
+func (ks *komainuSQLite) transaction(fn func(tx Tx) error) ([]ChangeEvent, error) {
+	tx, err := ks.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("storage failed to open transaction: %w", err)
+	}
+	kst := &komainuSQLiteTx{tx: tx}
+	if err := fn(kst); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return kst.changes, tx.Commit()
+}
+
+func (ks *komainuSQLite) Set(guildID discord.GuildID, collection string, key any, value any) (err error) {
+	return ks.Batch(func(tx Tx) error {
+		return tx.Set(guildID, collection, key, value)
+	})
+}
+
+func (ks *komainuSQLite) SetWithTTL(guildID discord.GuildID, collection string, key any, value any, ttl time.Duration) (err error) {
+	return ks.Batch(func(tx Tx) error {
+		return tx.SetWithTTL(guildID, collection, key, value, ttl)
+	})
+}
+
+func (ks *komainuSQLite) Increment(guildID discord.GuildID, collection string, key any, delta int64) (count int64, err error) {
+	return incrementInBatch(ks, guildID, collection, key, delta)
+}
+
+func (ks *komainuSQLite) Get(guildID discord.GuildID, collection string, key any, out any) (found bool, err error) {
+	err = ks.view(func(tx Tx) (err error) {
+		found, err = tx.Get(guildID, collection, key, out)
+		return
+	})
+	return
+}
+
+func (ks *komainuSQLite) Delete(guildID discord.GuildID, collection string, key any) (err error) {
+	return ks.Batch(func(tx Tx) error {
+		return tx.Delete(guildID, collection, key)
+	})
+}
+
+func (ks *komainuSQLite) Keys(guildID discord.GuildID, collection string) (keys []string, err error) {
+	err = ks.view(func(tx Tx) (err error) {
+		keys, err = tx.Keys(guildID, collection)
+		return
+	})
+	return
+}
+
+func (ks *komainuSQLite) ForEach(guildID discord.GuildID, collection string, fn ForEachFunc) (err error) {
+	return ks.view(func(tx Tx) error {
+		return tx.ForEach(guildID, collection, fn)
+	})
+}
+
+func (ks *komainuSQLite) Scan(guildID discord.GuildID, collection string, prefix string, limit int, fn ForEachFunc) (err error) {
+	return ks.view(func(tx Tx) error {
+		return tx.Scan(guildID, collection, prefix, limit, fn)
+	})
+}
+
+func (ks *komainuSQLite) Expired(now time.Time) (expired []ExpiredKey, err error) {
+	rows, err := ks.db.Query(`SELECT guild, key, value FROM kv WHERE collection = ?`, expiryCollection)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite store failed to list expiries: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var guild, rawKey string
+		var raw []byte
+		if err := rows.Scan(&guild, &rawKey, &raw); err != nil {
+			return nil, err
+		}
+		var expires int64
+		if err := decodeValue(raw, &expires); err != nil {
+			return nil, fmt.Errorf("storage failed to decode expiry for %s: %w", rawKey, err)
+		}
+		if expires > now.Unix() {
+			continue
+		}
+		snowflake, err := discord.ParseSnowflake(guild)
+		if err != nil {
+			continue // Not a guild, so nothing we put an expiry on.
+		}
+		if key, ok := parseExpiryKey(discord.GuildID(snowflake), rawKey); ok {
+			expired = append(expired, key)
+		}
+	}
+	return expired, rows.Err()
+}
+
+func (ks *komainuSQLite) PurgeGuild(guildID discord.GuildID) error {
+	ks.writer.Lock()
+	_, err := ks.db.Exec(`DELETE FROM kv WHERE guild = ?`, guildID.String())
+	ks.writer.Unlock()
+	if err != nil {
+		return fmt.Errorf("sqlite store failed to delete guild: %w", err)
+	}
+	ks.notify([]ChangeEvent{{GuildID: guildID, Deleted: true}})
+	return nil
+}
+
+func (ks *komainuSQLite) Close() error {
+	return ks.db.Close()
+}
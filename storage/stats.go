@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrStatsUnsupported is returned when asked for statistics from a KeyValueStore that can't provide them.
+var ErrStatsUnsupported = errors.New("this storage does not support statistics")
+
+// ErrCompactUnsupported is returned when asked to compact a KeyValueStore that can't do that.
+var ErrCompactUnsupported = errors.New("this storage does not support compaction")
+
+// CollectionStats tells how much one collection of one guild is using.
+type CollectionStats struct {
+	Keys  int
+	Bytes int64
+}
+
+// StorageStats tells what is using the space in a KeyValueStore.
+type StorageStats struct {
+	Guilds   map[discord.GuildID]map[string]CollectionStats
+	FileSize int64
+}
+
+// Statter is implemented by any KeyValueStore that can tell what is using its space.
+type Statter interface {
+	Stats() (StorageStats, error)
+}
+
+// Compactor is implemented by any KeyValueStore that can get rid of the space left behind by deleted keys, while in use.
+type Compactor interface {
+	Compact() (before int64, after int64, err error)
+}
+
+// Stats gets the statistics for the given KeyValueStore, if it can provide them.
+func Stats(kvs KeyValueStore) (StorageStats, error) {
+	statter, ok := kvs.(Statter)
+	if !ok {
+		return StorageStats{}, ErrStatsUnsupported
+	}
+	return statter.Stats()
+}
+
+// Compact compacts the given KeyValueStore, if it can be. Returns the size before and after.
+func Compact(kvs KeyValueStore) (before int64, after int64, err error) {
+	compactor, ok := kvs.(Compactor)
+	if !ok {
+		return 0, 0, ErrCompactUnsupported
+	}
+	return compactor.Compact()
+}
+
+// add counts a key in the given guild and collection.
+func (stats *StorageStats) add(guildID discord.GuildID, collection string, keys int, bytes int64) {
+	guild, ok := stats.Guilds[guildID]
+	if !ok {
+		guild = map[string]CollectionStats{}
+		stats.Guilds[guildID] = guild
+	}
+	counts := guild[collection]
+	counts.Keys += keys
+	counts.Bytes += bytes
+	guild[collection] = counts
+}
+
+// String formats the statistics as a table suitable for a terminal, with a total line for each guild.
+func (stats StorageStats) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "File size: %d bytes, guilds: %d\n\n", stats.FileSize, len(stats.Guilds))
+
+	guilds := make([]discord.GuildID, 0, len(stats.Guilds))
+	for guildID := range stats.Guilds {
+		guilds = append(guilds, guildID)
+	}
+	sort.Slice(guilds, func(i, j int) bool { return guilds[i] < guilds[j] })
+
+	table := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "Guild\tCollection\tKeys\tBytes\t")
+	for _, guildID := range guilds {
+		collections := make([]string, 0, len(stats.Guilds[guildID]))
+		for name := range stats.Guilds[guildID] {
+			collections = append(collections, name)
+		}
+		sort.Strings(collections)
+		total := CollectionStats{}
+		for _, name := range collections {
+			counts := stats.Guilds[guildID][name]
+			fmt.Fprintf(table, "%s\t%s\t%d\t%d\t\n", guildID, name, counts.Keys, counts.Bytes)
+			total.Keys += counts.Keys
+			total.Bytes += counts.Bytes
+		}
+		fmt.Fprintf(table, "%s\t(total)\t%d\t%d\t\n", guildID, total.Keys, total.Bytes)
+	}
+	table.Flush()
+	return sb.String()
+}
+
+// Stats reads the key counts and bytes in use from the bolt bucket stats, so the bytes include bolt's own overhead.
+func (kb *komainuBolt) Stats() (stats StorageStats, err error) {
+	kb.swap.RLock()
+	defer kb.swap.RUnlock()
+	stats.Guilds = map[discord.GuildID]map[string]CollectionStats{}
+	err = kb.bolt.View(func(tx *bolt.Tx) error {
+		stats.FileSize = tx.Size()
+		return tx.ForEach(func(guild []byte, guildBucket *bolt.Bucket) error {
+			snowflake, err := discord.ParseSnowflake(string(guild))
+			if err != nil {
+				return nil // Not a guild.
+			}
+			return guildBucket.ForEach(func(collection []byte, v []byte) error {
+				if v != nil {
+					return nil // A value, not a collection bucket.
+				}
+				bucketStats := guildBucket.Bucket(collection).Stats()
+				stats.add(discord.GuildID(snowflake), string(collection), bucketStats.KeyN, int64(bucketStats.LeafInuse+bucketStats.BranchInuse))
+				return nil
+			})
+		})
+	})
+	return
+}
+
+// renameFile swaps the compacted file in. Tests replace it to make the swap fail.
+var renameFile = os.Rename
+
+// Compact copies everything into a fresh file with bolt.Compact, and swaps it in for the old one.
+// Everything else waits while this happens. The old file stays open until the compacted one has taken its place,
+// so when anything goes wrong the store carries on as it was.
+func (kb *komainuBolt) Compact() (before int64, after int64, err error) {
+	kb.swap.Lock()
+	defer kb.swap.Unlock()
+	path := kb.path
+	compactPath := path + ".compact"
+	if info, err := os.Stat(path); err == nil {
+		before = info.Size()
+	}
+
+	os.Remove(compactPath) // Leftovers from a compaction that failed halfway.
+	compacted, err := bolt.Open(compactPath, 0660, nil)
+	if err != nil {
+		return before, 0, fmt.Errorf("could not create compacted file: %w", err)
+	}
+	if err := bolt.Compact(compacted, kb.bolt, 0); err != nil {
+		compacted.Close()
+		os.Remove(compactPath)
+		return before, 0, fmt.Errorf("could not compact: %w", err)
+	}
+	// The open compacted file is moved over the old one, so there is never a moment without a working database.
+	if err := renameFile(compactPath, path); err != nil {
+		compacted.Close()
+		os.Remove(compactPath)
+		return before, 0, fmt.Errorf("could not swap in compacted file: %w", err)
+	}
+	if err := kb.bolt.Close(); err != nil {
+		log.Printf("Failed to close the database that was compacted: %s\n", err)
+	}
+	kb.bolt = compacted
+	if info, err := os.Stat(path); err == nil {
+		after = info.Size()
+	}
+	return before, after, nil
+}
+
+// Stats counts the key and value bytes stored, as SQLite doesn't track it per guild and collection.
+func (ks *komainuSQLite) Stats() (stats StorageStats, err error) {
+	stats.Guilds = map[discord.GuildID]map[string]CollectionStats{}
+	if err := ks.db.QueryRow(`SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()`).Scan(&stats.FileSize); err != nil {
+		return stats, fmt.Errorf("sqlite store failed to get file size: %w", err)
+	}
+	rows, err := ks.db.Query(`SELECT guild, collection, COUNT(*), SUM(length(key) + length(value)) FROM kv GROUP BY guild, collection`)
+	if err != nil {
+		return stats, fmt.Errorf("sqlite store failed to get stats: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var guild, collection string
+		var keys int
+		var bytes int64
+		if err := rows.Scan(&guild, &collection, &keys, &bytes); err != nil {
+			return stats, err
+		}
+		snowflake, err := discord.ParseSnowflake(guild)
+		if err != nil {
+			continue // Not a guild.
+		}
+		stats.add(discord.GuildID(snowflake), collection, keys, bytes)
+	}
+	return stats, rows.Err()
+}
+
+// Compact runs VACUUM, which rebuilds the database file in place.
+func (ks *komainuSQLite) Compact() (before int64, after int64, err error) {
+	ks.writer.Lock()
+	defer ks.writer.Unlock()
+	size := func() (size int64) {
+		ks.db.QueryRow(`SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()`).Scan(&size)
+		return
+	}
+	before = size()
+	if _, err := ks.db.Exec(`VACUUM`); err != nil {
+		return before, 0, fmt.Errorf("sqlite store failed to vacuum: %w", err)
+	}
+	return before, size(), nil
+}
+
+// Stats counts the key and value bytes stored. There is no file, so FileSize is the total of those.
+func (ms *memoryStore) Stats() (stats StorageStats, err error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	stats.Guilds = map[discord.GuildID]map[string]CollectionStats{}
+	for guildID, guild := range ms.guilds {
+		for collection, bucket := range guild {
+			for key, value := range bucket {
+				stats.add(guildID, collection, 1, int64(len(key)+len(value)))
+				stats.FileSize += int64(len(key) + len(value))
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestStatsAndCompact(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		for i := 0; i < 500; i++ {
+			if err := kvs.Set(testGuild, col, i, fmt.Sprintf("some arbituary string number %d", i)); err != nil {
+				t.Errorf("Could not set test input value: %v", err)
+				return
+			}
+		}
+		err := kvs.Batch(func(tx Tx) error {
+			for i := 0; i < 400; i++ {
+				if err := tx.Delete(testGuild, col, i); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Could not delete test values: %v", err)
+			return
+		}
+
+		stats, err := Stats(kvs)
+		if err != nil {
+			t.Errorf("Could not get stats: %v", err)
+			return
+		}
+		counts := stats.Guilds[testGuild][col]
+		if counts.Keys != 100 || counts.Bytes <= 0 {
+			t.Errorf("Expected 100 keys using some bytes, Got %+v", counts)
+		}
+		if stats.Guilds[testGuild][schemaCollection].Keys != 1 {
+			t.Errorf("Expected the schema version to be counted, Got %+v", stats.Guilds[testGuild])
+		}
+
+		before, after, err := Compact(kvs)
+		if err == ErrCompactUnsupported {
+			return
+		}
+		if err != nil {
+			t.Errorf("Could not compact: %v", err)
+			return
+		}
+		if after > before {
+			t.Errorf("Compacting grew the file from %d to %d bytes", before, after)
+		}
+		var output string
+		if found, err := kvs.Get(testGuild, col, 450, &output); err != nil || !found || output != "some arbituary string number 450" {
+			t.Errorf("Expected the value to survive compaction, Got %q (%v)", output, err)
+		}
+		if err := kvs.Set(testGuild, col, "after", "compaction"); err != nil {
+			t.Errorf("Could not write after compaction: %v", err)
+		}
+	})
+}
+
+func TestCompactFailureKeepsDatabase(t *testing.T) {
+	kvs, err := GetKVS(filename)
+	if err != nil {
+		t.Errorf("Could not open test file: %s", err)
+		return
+	}
+	t.Cleanup(func() {
+		kvs.Close()
+		os.Remove(filename)
+		renameFile = os.Rename
+	})
+	if err := kvs.Set(testGuild, col, "kept", "value"); err != nil {
+		t.Errorf("Could not set test input value: %v", err)
+		return
+	}
+
+	renameFile = func(string, string) error { return errors.New("on purpose") }
+	if _, _, err := Compact(kvs); err == nil {
+		t.Error("Expected compacting to fail")
+	}
+	if _, err := os.Stat(filename + ".compact"); !os.IsNotExist(err) {
+		t.Errorf("Expected the compacted file to be cleaned up, Got %v", err)
+	}
+	var output string
+	if found, err := kvs.Get(testGuild, col, "kept", &output); err != nil || !found || output != "value" {
+		t.Errorf("Expected the database to still work after a failed compaction, Got %q (%v)", output, err)
+	}
+	if err := kvs.Set(testGuild, col, "after", "compaction"); err != nil {
+		t.Errorf("Could not write after a failed compaction: %v", err)
+	}
+
+	renameFile = os.Rename
+	if _, _, err := Compact(kvs); err != nil {
+		t.Errorf("Could not compact: %v", err)
+		return
+	}
+	if _, _, err := Compact(kvs); err != nil {
+		t.Errorf("Could not compact a compacted database: %v", err)
+		return
+	}
+	if found, err := kvs.Get(testGuild, col, "after", &output); err != nil || !found || output != "compaction" {
+		t.Errorf("Expected the value written after the failure to survive compaction, Got %q (%v)", output, err)
+	}
+}
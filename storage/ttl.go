@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// expiryCollection holds, per guild, when keys set with SetWithTTL are due to expire.
+const expiryCollection = "expiry"
+
+// ExpiredKey identifies a key that has outlived its TTL.
+type ExpiredKey struct {
+	GuildID    discord.GuildID
+	Collection string
+	Key        string
+}
+
+// ExpireFunc is called for each expired key in the collection it is registered for, just before the key is removed.
+// The value is still there to be read, if needed. Returning an error keeps the key, so it is tried again on the next sweep.
+type ExpireFunc func(kvs KeyValueStore, expired ExpiredKey) error
+
+var expireCallbacks = map[string]ExpireFunc{}
+
+// OnExpire sets what function should be called when keys in the given collection expire.
+func OnExpire(collection string, callback ExpireFunc) {
+	expireCallbacks[collection] = callback
+}
+
+// expiryKey is the key an expiry time is stored under in the expiryCollection.
+func expiryKey(collection string, key string) string {
+	return collection + "/" + key
+}
+
+// parseExpiryKey splits an expiry key back into the collection and key it is for.
+func parseExpiryKey(guildID discord.GuildID, raw string) (expired ExpiredKey, ok bool) {
+	parts := strings.SplitN(raw, "/", 2)
+	if len(parts) != 2 {
+		return expired, false
+	}
+	return ExpiredKey{GuildID: guildID, Collection: parts[0], Key: parts[1]}, true
+}
+
+// expiryTime turns a TTL into the unix timestamp it runs out at.
+func expiryTime(ttl time.Duration) int64 {
+	return time.Now().Add(ttl).Unix()
+}
+
+// SweepExpired removes every key that has expired, calling any registered ExpireFunc first.
+// Keys whose ExpireFunc fails are kept for the next sweep. Failing to remove a key doesn't stop the rest from being swept.
+func SweepExpired(kvs KeyValueStore) error {
+	expired, err := kvs.Expired(time.Now())
+	if err != nil {
+		return fmt.Errorf("could not list expired keys: %w", err)
+	}
+	for _, key := range expired {
+		if callback, ok := expireCallbacks[key.Collection]; ok {
+			if err := callback(kvs, key); err != nil {
+				log.Printf("[%s] Keeping expired key %s/%s to try again on the next sweep: %s", key.GuildID, key.Collection, key.Key, err)
+				continue
+			}
+		}
+		if err := kvs.Delete(key.GuildID, key.Collection, key.Key); err != nil {
+			log.Printf("[%s] Could not remove expired key %s/%s: %s", key.GuildID, key.Collection, key.Key, err)
+			continue
+		}
+	}
+	return nil
+}
+
+// StartSweepingExpiredKeys calls SweepExpired at the configured interval.
+// Intended to be called as a goroutine.
+func StartSweepingExpiredKeys(kvs KeyValueStore) {
+	tickEvery(func(cfg Configuration) time.Duration { return cfg.Intervals.Sweep.Value() }, func() {
+		if err := SweepExpired(kvs); err != nil {
+			log.Printf("Error encountered sweeping expired keys: %s", err)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSweepExpired(t *testing.T) {
+	const ttlCol = "test ttl collection"
+	var called []string
+	OnExpire(ttlCol, func(kvs KeyValueStore, expired ExpiredKey) error {
+		var value string
+		if exist, _ := kvs.Get(expired.GuildID, expired.Collection, expired.Key, &value); !exist {
+			t.Errorf("Value for %s was already gone when the callback ran", expired.Key)
+		}
+		called = append(called, value)
+		return nil
+	})
+	t.Cleanup(func() {
+		delete(expireCallbacks, ttlCol)
+	})
+
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		called = nil
+		if err := kvs.SetWithTTL(testGuild, ttlCol, "gone", "gone value", -time.Second); err != nil {
+			t.Errorf("Could not set value with TTL: %v", err)
+			return
+		}
+		if err := kvs.SetWithTTL(testGuild, ttlCol, "later", "later value", time.Hour); err != nil {
+			t.Errorf("Could not set value with TTL: %v", err)
+			return
+		}
+		if err := kvs.SetWithTTL(testGuild, ttlCol, "forever", "forever value", -time.Second); err != nil {
+			t.Errorf("Could not set value with TTL: %v", err)
+			return
+		}
+		if err := kvs.Set(testGuild, ttlCol, "forever", "forever value"); err != nil {
+			t.Errorf("Could not set value without TTL: %v", err)
+			return
+		}
+
+		if err := SweepExpired(kvs); err != nil {
+			t.Errorf("Could not sweep: %v", err)
+			return
+		}
+		if len(called) != 1 || called[0] != "gone value" {
+			t.Errorf("Expected the callback for just the gone value, Got %v", called)
+		}
+		keys, err := kvs.Keys(testGuild, ttlCol)
+		if err != nil {
+			t.Errorf("Could not list keys: %v", err)
+			return
+		}
+		if len(keys) != 2 || keys[0] != "forever" || keys[1] != "later" {
+			t.Errorf("Expected [forever later], Got %v", keys)
+		}
+	})
+}
+
+func TestSweepExpiredRetries(t *testing.T) {
+	const retryCol = "test retry collection"
+	failing := true
+	OnExpire(retryCol, func(kvs KeyValueStore, expired ExpiredKey) error {
+		if failing {
+			return errors.New("discord is having a moment")
+		}
+		return nil
+	})
+	t.Cleanup(func() {
+		delete(expireCallbacks, retryCol)
+	})
+
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		failing = true
+		if err := kvs.SetWithTTL(testGuild, retryCol, "vote", "value", -time.Second); err != nil {
+			t.Errorf("Could not set value with TTL: %v", err)
+			return
+		}
+		if err := SweepExpired(kvs); err != nil {
+			t.Errorf("Could not sweep: %v", err)
+			return
+		}
+		if exist, _ := kvs.Get(testGuild, retryCol, "vote", new(string)); !exist {
+			t.Error("Expected the key to be kept when its callback failed")
+			return
+		}
+		failing = false
+		if err := SweepExpired(kvs); err != nil {
+			t.Errorf("Could not sweep: %v", err)
+			return
+		}
+		if exist, _ := kvs.Get(testGuild, retryCol, "vote", new(string)); exist {
+			t.Error("Expected the key to be removed once its callback succeeded")
+		}
+	})
+}
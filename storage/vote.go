@@ -1,8 +1,10 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"sort"
 	"strings"
 	"time"
@@ -10,6 +12,7 @@ import (
 	"github.com/diamondburned/arikawa/v3/api"
 	"github.com/diamondburned/arikawa/v3/discord"
 	"github.com/diamondburned/arikawa/v3/state"
+	"github.com/diamondburned/arikawa/v3/utils/httputil"
 	"github.com/diamondburned/arikawa/v3/utils/json/option"
 )
 
@@ -26,9 +29,17 @@ type Vote struct {
 	Votes     map[discord.UserID]string
 }
 
-// Store saves the vote struct to kvs
-func (vote *Vote) Store(kvs KeyValueStore) error {
-	return kvs.Set(vote.GuildID, "votes", vote.MessageID, vote)
+func init() {
+	RegisterSchemaMigration(SchemaMigration{
+		Version:     2,
+		Description: "Give open votes an expiry, instead of polling them",
+		Migrate:     migrateVoteExpiry,
+	})
+}
+
+// Store saves the vote struct to kvs, set to expire when voting closes.
+func (vote *Vote) Store(kvs Tx) error {
+	return kvs.SetWithTTL(vote.GuildID, "votes", vote.MessageID, vote, time.Until(time.Unix(vote.EndTime, 0)))
 }
 
 // Tally returns the current vote tally along with a slice containing the different vote options for easy sorting.
@@ -86,60 +97,66 @@ func GetVote(kvs KeyValueStore, guildID discord.GuildID, messageID discord.Messa
 	return exist, vote, err
 }
 
-// CloseExpiredVotes iterates over all the known votes in the connected guilds, and closes the ended ones.
-func CloseExpiredVotes(state *state.State, kvs KeyValueStore) error {
-	guilds, err := state.Guilds()
-	if err != nil {
-		return fmt.Errorf("closing expired votes could not fetch current guilds: %w", err)
-	}
-	now := time.Now().Unix()
-	for _, guild := range guilds {
-		keys, err := kvs.Keys(guild.ID, "votes")
+// CloseExpiredVote returns an ExpireFunc that edits the vote message to show the final tally.
+// Register it with OnExpire for the "votes" collection. If the message can't be edited, the vote is kept to try again,
+// unless the message or channel is gone, in which case there is nothing left to close.
+func CloseExpiredVote(state *state.State) ExpireFunc {
+	return func(kvs KeyValueStore, expired ExpiredKey) error {
+		vote := Vote{}
+		exist, err := kvs.Get(expired.GuildID, "votes", expired.Key, &vote)
 		if err != nil {
-			return fmt.Errorf("closing expired votes could not get keys for guild: %w", err)
+			return fmt.Errorf("could not obtain vote object: %w", err)
 		}
-		for _, key := range keys {
-			vote := Vote{}
-			exist, err := kvs.Get(guild.ID, "votes", key, &vote)
-			if err != nil {
-				return fmt.Errorf("closing expired votes could not obtain vote object: %w", err)
-			}
-			if exist {
-				if vote.ChannelID == discord.NullChannelID || vote.ChannelID == 0 {
-					log.Printf("[%s] Closing expired votes encountered vote with no channel ID -- PURGING", guild.ID)
-					err := kvs.Delete(guild.ID, "votes", key)
-					if err != nil {
-						log.Printf("[%s] Error purging invalid vote: %s\n", guild.ID, err)
-					}
-					continue
-				}
-				if vote.EndTime <= now {
-					_, err := state.EditMessageComplex(vote.ChannelID, vote.MessageID, api.EditMessageData{
-						Content:    option.NewNullableString(vote.String()),
-						Components: &discord.ContainerComponents{},
-					})
-					if err != nil {
-						return fmt.Errorf("closing expired votes could not update vote message: %w", err)
-					}
-					err = kvs.Delete(guild.ID, "votes", key)
-					if err != nil {
-						return fmt.Errorf("encoutered an error removing expired vote: %w", err)
-					}
-				}
-			}
+		if !exist {
+			return nil
+		}
+		if vote.ChannelID == discord.NullChannelID || vote.ChannelID == 0 {
+			log.Printf("[%s] Closing expired vote encountered vote with no channel ID -- PURGING", expired.GuildID)
+			return nil
+		}
+		_, err = state.EditMessageComplex(vote.ChannelID, vote.MessageID, api.EditMessageData{
+			Content:    option.NewNullableString(vote.String()),
+			Components: &discord.ContainerComponents{},
+		})
+		var httpErr *httputil.HTTPError
+		if errors.As(err, &httpErr) && httpErr.Status == http.StatusNotFound {
+			log.Printf("[%s] Closing expired vote found its message gone -- PURGING", expired.GuildID)
+			return nil
 		}
+		if err != nil {
+			return fmt.Errorf("could not update vote message: %w", err)
+		}
+		return nil
 	}
-	return nil
 }
 
-// StartClosingExpiredVotes starts a ticker and, once a minute, calls CloseExpiredVotes.
-// Intended to be called as a goroutine.
-func StartClosingExpiredVotes(state *state.State, kvs KeyValueStore) {
-	ticker := time.NewTicker(1 * time.Minute)
-	for {
-		<-ticker.C
-		if err := CloseExpiredVotes(state, kvs); err != nil {
-			log.Printf("Error encountered closing expired votes: %s", err)
+// migrateVoteExpiry stores every vote again, so it picks up an expiry. Votes with no channel can never be closed, so they are purged.
+func migrateVoteExpiry(kvs Tx, guildID discord.GuildID) error {
+	votes := map[string]Vote{}
+	err := kvs.ForEach(guildID, "votes", func(key string, value ValueDecoder) error {
+		vote := Vote{}
+		if err := value(&vote); err != nil {
+			return fmt.Errorf("could not decode vote object: %w", err)
 		}
+		votes[key] = vote
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	// Changing the collection while iterating it is a bad idea, so that happens separately.
+	// Votes are purged and stored again under the key they were found under, as their MessageID may not match it.
+	for key, vote := range votes {
+		if vote.ChannelID == discord.NullChannelID || vote.ChannelID == 0 {
+			log.Printf("[%s] Vote with no channel ID found during migration -- PURGING", guildID)
+			if err := kvs.Delete(guildID, "votes", key); err != nil {
+				return fmt.Errorf("could not purge invalid vote: %w", err)
+			}
+			continue
+		}
+		if err := kvs.SetWithTTL(guildID, "votes", key, vote, time.Until(time.Unix(vote.EndTime, 0))); err != nil {
+			return fmt.Errorf("could not store vote with expiry: %w", err)
+		}
+	}
+	return nil
 }
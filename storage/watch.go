@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// ChangeEvent tells a subscriber that a key was set or deleted.
+// An event with an empty Collection means everything stored for the guild was removed.
+type ChangeEvent struct {
+	GuildID    discord.GuildID
+	Collection string
+	Key        string
+	Deleted    bool
+}
+
+// ChangeFunc is called with each change a subscription matches, after the change is committed.
+// It is called from whatever goroutine made the change, so keep it quick, and don't make changes from inside it.
+type ChangeFunc func(event ChangeEvent)
+
+// subscription is what Subscribe was asked to watch for.
+type subscription struct {
+	guildID    discord.GuildID
+	collection string
+	fn         ChangeFunc
+}
+
+// matches checks if the subscription cares about the given event.
+func (sub subscription) matches(event ChangeEvent) bool {
+	if sub.guildID.IsValid() && sub.guildID != event.GuildID {
+		return false
+	}
+	return event.Collection == "" || sub.collection == event.Collection
+}
+
+// watchers keeps track of the subscriptions to a KeyValueStore.
+type watchers struct {
+	mutex sync.RWMutex
+	next  int
+	subs  map[int]subscription
+}
+
+// Subscribe calls fn for each change to the given collection in the given guild, or in every guild if given discord.NullGuildID.
+// Call the returned function to stop.
+func (w *watchers) Subscribe(guildID discord.GuildID, collection string, fn ChangeFunc) (unsubscribe func()) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.subs == nil {
+		w.subs = map[int]subscription{}
+	}
+	id := w.next
+	w.next++
+	w.subs[id] = subscription{guildID, collection, fn}
+	return func() {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		delete(w.subs, id)
+	}
+}
+
+// notify hands the committed changes to everyone subscribed to them.
+func (w *watchers) notify(changes []ChangeEvent) {
+	if len(changes) == 0 {
+		return
+	}
+	w.mutex.RLock()
+	subs := make([]subscription, 0, len(w.subs))
+	for _, sub := range w.subs {
+		subs = append(subs, sub)
+	}
+	w.mutex.RUnlock()
+	for _, event := range changes {
+		for _, sub := range subs {
+			if sub.matches(event) {
+				sub.fn(event)
+			}
+		}
+	}
+}
+
+// KeyCache keeps the sorted keys of one collection in memory, for every guild and KeyValueStore it is used with.
+// A guild is read once, and then kept in sync through Subscribe.
+type KeyCache struct {
+	collection string
+	mutex      sync.Mutex
+	stores     map[KeyValueStore]map[discord.GuildID][]string
+	generation int // Counts changes, so a read that raced with one isn't cached.
+}
+
+// NewKeyCache creates a KeyCache for the given collection.
+func NewKeyCache(collection string) *KeyCache {
+	return &KeyCache{
+		collection: collection,
+		stores:     map[KeyValueStore]map[discord.GuildID][]string{},
+	}
+}
+
+// Keys returns the keys in the collection for the given guild, in key order. Don't change the returned slice.
+func (kc *KeyCache) Keys(kvs KeyValueStore, guildID discord.GuildID) ([]string, error) {
+	kc.mutex.Lock()
+	guilds, ok := kc.stores[kvs]
+	if !ok {
+		guilds = map[discord.GuildID][]string{}
+		kc.stores[kvs] = guilds
+		kvs.Subscribe(discord.NullGuildID, kc.collection, kc.changed(kvs))
+	}
+	keys, cached := guilds[guildID]
+	generation := kc.generation
+	kc.mutex.Unlock()
+	if cached {
+		return keys, nil
+	}
+
+	keys, err := kvs.Keys(guildID, kc.collection)
+	if err != nil {
+		return nil, err
+	}
+	kc.mutex.Lock()
+	defer kc.mutex.Unlock()
+	if kc.generation == generation {
+		guilds[guildID] = keys
+	}
+	return keys, nil
+}
+
+// changed makes the ChangeFunc that keeps the cache for the given KeyValueStore in sync.
+func (kc *KeyCache) changed(kvs KeyValueStore) ChangeFunc {
+	return func(event ChangeEvent) {
+		kc.mutex.Lock()
+		defer kc.mutex.Unlock()
+		kc.generation++
+		guilds := kc.stores[kvs]
+		keys, cached := guilds[event.GuildID]
+		if !cached {
+			return // Will be read fresh when needed.
+		}
+		if event.Collection == "" {
+			delete(guilds, event.GuildID)
+			return
+		}
+		i := sort.SearchStrings(keys, event.Key)
+		exists := i < len(keys) && keys[i] == event.Key
+		updated := make([]string, 0, len(keys)+1) // A fresh slice, as the old one may still be in use.
+		switch {
+		case event.Deleted && exists:
+			updated = append(append(updated, keys[:i]...), keys[i+1:]...)
+		case !event.Deleted && !exists:
+			updated = append(append(append(updated, keys[:i]...), event.Key), keys[i:]...)
+		default:
+			return
+		}
+		guilds[event.GuildID] = updated
+	}
+}
+
+// ValueCache keeps one key of one collection in memory, for every guild and KeyValueStore it is used with.
+// A guild is read once, and then kept in sync through Subscribe.
+type ValueCache[T any] struct {
+	collection string
+	key        string
+	mutex      sync.Mutex
+	stores     map[KeyValueStore]map[discord.GuildID]cachedValue[T]
+	generation int // Counts changes, so a read that raced with one isn't cached.
+}
+
+type cachedValue[T any] struct {
+	value T
+	exist bool
+}
+
+// NewValueCache creates a ValueCache for the given key in the given collection.
+func NewValueCache[T any](collection string, key string) *ValueCache[T] {
+	return &ValueCache[T]{
+		collection: collection,
+		key:        key,
+		stores:     map[KeyValueStore]map[discord.GuildID]cachedValue[T]{},
+	}
+}
+
+// Get works like KeyValueStore.Get, but only reads the KeyValueStore when the value isn't cached.
+func (vc *ValueCache[T]) Get(kvs KeyValueStore, guildID discord.GuildID) (value T, exist bool, err error) {
+	vc.mutex.Lock()
+	guilds, ok := vc.stores[kvs]
+	if !ok {
+		guilds = map[discord.GuildID]cachedValue[T]{}
+		vc.stores[kvs] = guilds
+		kvs.Subscribe(discord.NullGuildID, vc.collection, vc.changed(kvs))
+	}
+	cached, isCached := guilds[guildID]
+	generation := vc.generation
+	vc.mutex.Unlock()
+	if isCached {
+		return cached.value, cached.exist, nil
+	}
+
+	exist, err = kvs.Get(guildID, vc.collection, vc.key, &value)
+	if err != nil {
+		return value, false, err
+	}
+	vc.mutex.Lock()
+	defer vc.mutex.Unlock()
+	if vc.generation == generation {
+		guilds[guildID] = cachedValue[T]{value, exist}
+	}
+	return value, exist, nil
+}
+
+// changed makes the ChangeFunc that keeps the cache for the given KeyValueStore in sync.
+// Changes just drop the cached value, which is read again the next time it's needed.
+func (vc *ValueCache[T]) changed(kvs KeyValueStore) ChangeFunc {
+	return func(event ChangeEvent) {
+		if event.Collection != "" && event.Key != vc.key {
+			return
+		}
+		vc.mutex.Lock()
+		defer vc.mutex.Unlock()
+		vc.generation++
+		delete(vc.stores[kvs], event.GuildID)
+	}
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+func TestSubscribe(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		var events []ChangeEvent
+		unsubscribe := kvs.Subscribe(testGuild, col, func(event ChangeEvent) {
+			events = append(events, event)
+		})
+
+		kvs.Batch(func(tx Tx) error {
+			tx.Set(testGuild, col, "rolled back", "value")
+			return errors.New("on purpose")
+		})
+		if len(events) != 0 {
+			t.Errorf("Expected no events for a rolled back batch, Got %v", events)
+		}
+
+		kvs.Set(testGuild, col, "alpha", "value")
+		kvs.Set(testGuild, "other collection", "ignored", "value")
+		kvs.Set(testGuild+1, col, "ignored", "value")
+		kvs.Delete(testGuild, col, "alpha")
+		kvs.PurgeGuild(testGuild)
+		expected := []ChangeEvent{
+			{testGuild, col, "alpha", false},
+			{testGuild, col, "alpha", true},
+			{testGuild, "", "", true},
+		}
+		if len(events) != len(expected) {
+			t.Errorf("Expected %v, Got %v", expected, events)
+			return
+		}
+		for i := range expected {
+			if events[i] != expected[i] {
+				t.Errorf("Expected %v, Got %v", expected[i], events[i])
+			}
+		}
+
+		unsubscribe()
+		kvs.Set(testGuild, col, "bravo", "value")
+		if len(events) != len(expected) {
+			t.Errorf("Got an event after unsubscribing: %v", events[len(events)-1])
+		}
+	})
+}
+
+func TestCaches(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, kvs KeyValueStore) {
+		keyCache := NewKeyCache(col)
+		valueCache := NewValueCache[discord.RoleID](col, "role")
+		kvs.Set(testGuild, col, "bravo", "value")
+		kvs.Set(testGuild, col, "role", discord.RoleID(1))
+
+		if keys, err := keyCache.Keys(kvs, testGuild); err != nil || strings.Join(keys, ",") != "bravo,role" {
+			t.Errorf("Expected [bravo role], Got %v (%v)", keys, err)
+		}
+		if role, exist, err := valueCache.Get(kvs, testGuild); err != nil || !exist || role != 1 {
+			t.Errorf("Expected role 1, Got %v %v (%v)", role, exist, err)
+		}
+
+		kvs.Set(testGuild, col, "alpha", "value")
+		kvs.Delete(testGuild, col, "bravo")
+		kvs.Set(testGuild, col, "role", discord.RoleID(2))
+		if keys, _ := keyCache.Keys(kvs, testGuild); strings.Join(keys, ",") != "alpha,role" {
+			t.Errorf("Expected the key cache to follow along to [alpha role], Got %v", keys)
+		}
+		if role, _, _ := valueCache.Get(kvs, testGuild); role != 2 {
+			t.Errorf("Expected the value cache to follow along to role 2, Got %v", role)
+		}
+
+		kvs.Delete(testGuild, col, "role")
+		if _, exist, _ := valueCache.Get(kvs, testGuild); exist {
+			t.Error("Expected the value cache to notice the deletion")
+		}
+	})
+}
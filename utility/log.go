@@ -0,0 +1,23 @@
+package utility
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// debugLogging decides if Debugf says anything.
+var debugLogging atomic.Bool
+
+// SetLogLevel sets how chatty the log is. Only "debug" lets Debugf through.
+func SetLogLevel(level string) {
+	debugLogging.Store(level == "debug")
+}
+
+// Debugf logs like log.Printf, but only when the log level is "debug".
+// For things that happen so often they would drown out everything else.
+func Debugf(format string, v ...any) {
+	if debugLogging.Load() {
+		log.Output(2, fmt.Sprintf(format, v...))
+	}
+}
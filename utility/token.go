@@ -1,72 +1,116 @@
 package utility
 
 import (
+	"sync"
 	"time"
-
-	"github.com/diamondburned/arikawa/v3/discord"
 )
 
-type Token struct {
-	Interval int
-	count    int
-	ticker   *time.Ticker
+// tokenBinEvictEvery is how often a TokenBin looks for buckets it can forget about.
+const tokenBinEvictEvery = time.Minute
+
+// Limit is a rate: Max tokens per Interval. A bucket holds Max tokens, and they come back steadily, all of them over one Interval.
+// A Max below 1 means there is no limit at all.
+type Limit struct {
+	Max      int
+	Interval time.Duration
 }
 
-// Increment increments the count of the token, and starts a ticker to count it back down, as needed.
-func (token *Token) Increment() {
-	token.count++
-	if token.ticker == nil {
-		interval := 10
-		if token.Interval > 0 {
-			interval = token.Interval
-		}
-		token.ticker = time.NewTicker(time.Duration(interval) * time.Second)
-		go tick(token)
+// TokenBin holds a token bucket for each key, like a user in a guild. It's safe to use from any goroutine.
+// Buckets are refilled by working out how much time has passed since they were last used, so there is nothing ticking in the background,
+// and buckets that have filled up again are evicted, as they're no different from new ones. The zero value is ready to use.
+type TokenBin[K comparable] struct {
+	mutex     sync.Mutex
+	buckets   map[K]*bucket
+	lastEvict time.Time
+	now       func() time.Time // time.Now, unless a test says otherwise.
+}
+
+// bucket keeps track of the tokens taken from it, and how many of those are back as of updated.
+type bucket struct {
+	used    float64
+	updated time.Time
+	limit   Limit // The limit it was last used with, for eviction.
+}
+
+// refill gives back the tokens that came back since the bucket was last updated.
+func (b *bucket) refill(now time.Time) {
+	if b.limit.Interval > 0 {
+		b.used -= float64(now.Sub(b.updated)) * float64(b.limit.Max) / float64(b.limit.Interval)
+	} else {
+		b.used = 0
+	}
+	if b.used < 0 {
+		b.used = 0
 	}
+	b.updated = now
 }
 
-// tick does the ticker waiting, decrementing and cleanup.
-func tick(token *Token) {
-	for token.count > 0 {
-		<-token.ticker.C // Just wait for it to tick, we don't care what it returns.
-		token.count--
+// Allocate takes a token from the bucket for the key, if the limit allows it.
+// When it doesn't, it returns how long until the next token is back.
+func (tb *TokenBin[K]) Allocate(key K, limit Limit) (ok bool, wait time.Duration) {
+	if limit.Max < 1 {
+		return true, 0
 	}
-	token.ticker.Stop()
-	token.ticker = nil
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	now := tb.clock()
+	tb.evict(now)
+
+	b, exist := tb.buckets[key]
+	if !exist {
+		b = &bucket{updated: now}
+		tb.buckets[key] = b
+	}
+	b.limit = limit
+	b.refill(now)
+	if over := b.used + 1 - float64(limit.Max); over > 0 {
+		return false, time.Duration(over * float64(limit.Interval) / float64(limit.Max))
+	}
+	b.used++
+	return true, 0
 }
 
-// GetCount returns the current count on the token.
-func (token *Token) GetCount() int {
-	return token.count
+// Refund gives back a token taken by Allocate, for when what it was taken for didn't happen after all.
+func (tb *TokenBin[K]) Refund(key K) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	if b, exist := tb.buckets[key]; exist {
+		b.refill(tb.clock())
+		b.used--
+		if b.used < 0 {
+			b.used = 0
+		}
+	}
 }
 
-type TokenBin struct {
-	Max      int
-	Interval int
-	tokens   map[discord.Snowflake]map[discord.Snowflake]*Token
+// Len returns how many buckets are being kept track of.
+func (tb *TokenBin[K]) Len() int {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	return len(tb.buckets)
 }
 
-// ensureTokenExists makes sure the pile and key exists, and has a Token on it with the correct Interval
-func (tb *TokenBin) ensureTokenExists(pile discord.Snowflake, key discord.Snowflake) {
-	if tb.tokens == nil {
-		tb.tokens = map[discord.Snowflake]map[discord.Snowflake]*Token{}
+// clock returns the current time, and gets the TokenBin ready for use if it wasn't yet. Call with the mutex held.
+func (tb *TokenBin[K]) clock() time.Time {
+	if tb.buckets == nil {
+		tb.buckets = map[K]*bucket{}
 	}
-	if _, ok := tb.tokens[pile]; !ok {
-		tb.tokens[pile] = map[discord.Snowflake]*Token{}
-	}
-	if _, ok := tb.tokens[pile][key]; !ok {
-		tb.tokens[pile][key] = &Token{Interval: tb.Interval}
+	if tb.now == nil {
+		tb.now = time.Now
 	}
+	return tb.now()
 }
 
-// Allocate will return a true value if the token has not reached it's maximum value, false if it has.
-func (tb *TokenBin) Allocate(pile discord.Snowflake, key discord.Snowflake) bool {
-	tb.ensureTokenExists(pile, key)
-	if token, ok := tb.tokens[pile][key]; ok {
-		if token.GetCount() < tb.Max {
-			token.Increment()
-			return true
+// evict forgets the buckets that have filled up again, every once in a while. Call with the mutex held.
+func (tb *TokenBin[K]) evict(now time.Time) {
+	if now.Sub(tb.lastEvict) < tokenBinEvictEvery {
+		return
+	}
+	tb.lastEvict = now
+	for key, b := range tb.buckets {
+		b.refill(now)
+		if b.used == 0 {
+			delete(tb.buckets, key)
 		}
 	}
-	return false
 }
@@ -0,0 +1,172 @@
+package utility
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a time that only moves when told to.
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.now
+}
+
+func (clock *fakeClock) Advance(duration time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.now = clock.now.Add(duration)
+}
+
+func TestTokenBinAllocate(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	tb := &TokenBin[string]{now: clock.Now}
+	limit := Limit{Max: 3, Interval: 10 * time.Second}
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := tb.Allocate("user", limit); !ok {
+			t.Errorf("Expected token %d to be allocated", i+1)
+			return
+		}
+	}
+	ok, wait := tb.Allocate("user", limit)
+	if ok || wait != 10*time.Second/3 {
+		t.Errorf("Expected to be refused until a third of the interval has passed, Got %t and %s", ok, wait)
+	}
+	if ok, _ := tb.Allocate("someone else", limit); !ok {
+		t.Error("Expected another key to have a bucket of its own")
+	}
+
+	clock.Advance(2 * time.Second)
+	if _, wait := tb.Allocate("user", limit); wait <= 0 || wait >= 2*time.Second {
+		t.Errorf("Expected to wait a little over 1s more, Got %s", wait)
+	}
+	clock.Advance(5 * time.Second)
+	if ok, _ := tb.Allocate("user", limit); !ok {
+		t.Error("Expected a token to be back after 7s")
+	}
+	if ok, _ := tb.Allocate("user", limit); !ok {
+		t.Error("Expected a second token to be back after 7s, as they come back every third of the interval")
+	}
+	if ok, _ := tb.Allocate("user", limit); ok {
+		t.Error("Expected only two tokens to be back")
+	}
+
+	clock.Advance(10 * time.Second)
+	for i := 0; i < 3; i++ {
+		if ok, _ := tb.Allocate("user", limit); !ok {
+			t.Errorf("Expected all 3 tokens back after a whole interval, but token %d was refused", i+1)
+			return
+		}
+	}
+}
+
+func TestTokenBinLimitChanges(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	tb := &TokenBin[string]{now: clock.Now}
+
+	tb.Allocate("user", Limit{Max: 1, Interval: time.Minute})
+	if ok, _ := tb.Allocate("user", Limit{Max: 1, Interval: time.Minute}); ok {
+		t.Error("Expected the bucket to be empty")
+	}
+	if ok, _ := tb.Allocate("user", Limit{Max: 2, Interval: time.Minute}); !ok {
+		t.Error("Expected a raised limit to take effect right away")
+	}
+	for i := 0; i < 10; i++ {
+		if ok, _ := tb.Allocate("user", Limit{}); !ok {
+			t.Error("Expected no limit at all with a Max of 0")
+			return
+		}
+	}
+}
+
+func TestTokenBinSubSecond(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	tb := &TokenBin[string]{now: clock.Now}
+	limit := Limit{Max: 2, Interval: 500 * time.Millisecond}
+
+	tb.Allocate("user", limit)
+	tb.Allocate("user", limit)
+	ok, wait := tb.Allocate("user", limit)
+	if ok || wait != 250*time.Millisecond {
+		t.Errorf("Expected to be refused for 250ms, Got %t and %s", ok, wait)
+		return
+	}
+	clock.Advance(250 * time.Millisecond)
+	if ok, _ := tb.Allocate("user", limit); !ok {
+		t.Error("Expected a token to be back after 250ms")
+	}
+}
+
+func TestTokenBinRefund(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	tb := &TokenBin[string]{now: clock.Now}
+	limit := Limit{Max: 1, Interval: time.Minute}
+
+	tb.Allocate("user", limit)
+	tb.Refund("user")
+	if ok, _ := tb.Allocate("user", limit); !ok {
+		t.Error("Expected the refunded token to be back")
+	}
+	tb.Refund("user")
+	tb.Refund("user")
+	tb.Refund("nobody")
+	tb.Allocate("user", limit)
+	if ok, _ := tb.Allocate("user", limit); ok {
+		t.Error("Expected refunds to never give back more than the bucket holds")
+	}
+}
+
+func TestTokenBinEviction(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	tb := &TokenBin[int]{now: clock.Now}
+	short := Limit{Max: 5, Interval: time.Second}
+	long := Limit{Max: 5, Interval: time.Hour}
+
+	for i := 0; i < 100; i++ {
+		tb.Allocate(i, short)
+	}
+	tb.Allocate(-1, long)
+	if tb.Len() != 101 {
+		t.Errorf("Expected 101 buckets, Got %d", tb.Len())
+		return
+	}
+	clock.Advance(tokenBinEvictEvery)
+	tb.Allocate(-2, short)
+	if tb.Len() != 2 {
+		t.Errorf("Expected only the long bucket and the new one to be kept, Got %d", tb.Len())
+	}
+}
+
+func TestTokenBinConcurrent(t *testing.T) {
+	tb := &TokenBin[int]{}
+	limit := Limit{Max: 50, Interval: time.Hour}
+	allocated := make([]int, 4)
+	var wg sync.WaitGroup
+	for worker := 0; worker < 20; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				key := worker % len(allocated)
+				if ok, _ := tb.Allocate(key, limit); ok {
+					tb.mutex.Lock()
+					allocated[key]++
+					tb.mutex.Unlock()
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+	for key, count := range allocated {
+		if count != limit.Max {
+			t.Errorf("Expected key %d to get exactly %d tokens, Got %d", key, limit.Max, count)
+		}
+	}
+}